@@ -0,0 +1,36 @@
+package blocks
+
+import (
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+// RenderBackend creates the meshes that chunk geometry is written to. Meshes are created from the mesh
+// workers, so implementations must be safe for concurrent use.
+type RenderBackend interface {
+	CreateMesh() MeshRenderer
+
+	// CreateTransparentMesh creates a mesh for faces that are blended over the opaque ones.
+	CreateTransparentMesh() MeshRenderer
+}
+
+// NopBackend is a RenderBackend whose meshes discard everything written to them, for running a
+// ChunkManager without a display.
+type NopBackend struct{}
+
+func (NopBackend) CreateMesh() MeshRenderer {
+	return nopMesh{}
+}
+
+func (NopBackend) CreateTransparentMesh() MeshRenderer {
+	return nopMesh{}
+}
+
+type nopMesh struct{}
+
+func (nopMesh) AddVertex(p mgl64.Vec3) uint32       { return 0 }
+func (nopMesh) AddTriangle(v1, v2, v3 uint32)       {}
+func (nopMesh) SetColor(c mgl64.Vec3)               {}
+func (nopMesh) SetAlpha(a float64)                  {}
+func (nopMesh) SetLight(torchlight, sunlight uint8) {}
+func (nopMesh) Finish()                             {}
+func (nopMesh) TearDown()                           {}
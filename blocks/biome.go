@@ -0,0 +1,95 @@
+package blocks
+
+import (
+	"math"
+)
+
+// Biome describes the terrain found in one part of the climate. Each biome is strongest at its
+// Temperature and Humidity, both from -1 to 1, and fades into its neighbours away from them.
+type Biome struct {
+	Name string
+
+	Temperature, Humidity float64
+
+	// The surface rises and falls by up to HeightAmplitude around BaseHeight.
+	BaseHeight, HeightAmplitude float64
+
+	// Surface is the top block of the terrain, with SubsurfaceDepth Subsurface blocks beneath it.
+	Surface, Subsurface BlockType
+	SubsurfaceDepth     int
+}
+
+// DefaultBiomes returns the biomes used by DefaultDensityParams.
+func DefaultBiomes() []Biome {
+	return []Biome{
+		{
+			Name:            "plains",
+			Temperature:     0.1,
+			Humidity:        0.2,
+			BaseHeight:      2,
+			HeightAmplitude: 8,
+			Surface:         Grass,
+			Subsurface:      Dirt,
+			SubsurfaceDepth: 3,
+		},
+		{
+			Name:            "desert",
+			Temperature:     0.7,
+			Humidity:        -0.6,
+			BaseHeight:      4,
+			HeightAmplitude: 5,
+			Surface:         Sand,
+			Subsurface:      Sand,
+			SubsurfaceDepth: 5,
+		},
+		{
+			Name:            "hills",
+			Temperature:     -0.1,
+			Humidity:        -0.3,
+			BaseHeight:      10,
+			HeightAmplitude: 20,
+			Surface:         Grass,
+			Subsurface:      Dirt,
+			SubsurfaceDepth: 2,
+		},
+		{
+			Name:            "snowy mountains",
+			Temperature:     -0.7,
+			Humidity:        0.3,
+			BaseHeight:      28,
+			HeightAmplitude: 36,
+			Surface:         Snow,
+			Subsurface:      Stone,
+			SubsurfaceDepth: 1,
+		},
+	}
+}
+
+// blendBiomes returns the terrain height at a point with the given climate and height noise, and the
+// biome that contributes most to it. Every biome contributes to the height, weighted by how close its
+// climate is, so heights change gradually across biome borders rather than forming cliffs. blend is
+// how far apart in the climate the weights fall off.
+func blendBiomes(biomes []Biome, temperature, humidity, n, blend float64) (float64, *Biome) {
+	var dominant *Biome
+	var height, total, best float64
+
+	for i := range biomes {
+		b := &biomes[i]
+
+		dt, dh := temperature-b.Temperature, humidity-b.Humidity
+		w := math.Exp(-(dt*dt + dh*dh) / (2 * blend * blend))
+
+		height += w * (b.BaseHeight + b.HeightAmplitude*n)
+		total += w
+
+		if dominant == nil || w > best {
+			dominant, best = b, w
+		}
+	}
+
+	if total == 0 {
+		return height, dominant
+	}
+
+	return height / total, dominant
+}
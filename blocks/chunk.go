@@ -1,138 +1,144 @@
 package blocks
 
 import (
-	"container/list"
-	"math"
-
-	"github.com/nickbryan/voxel/noise"
+	"sync"
 
 	"github.com/go-gl/mathgl/mgl64"
 )
 
 type Chunk struct {
-	blocks   BlockContainer
+	blocks   *PaletteContainer
 	lightMap LightMapContainer
-	mesher   *CulledMesher
 
-	Mesh    MeshRenderer
-	Pos     mgl64.Vec3
-	GridPos mgl64.Vec3
+	// mu guards Meshes, Neighbours, unloaded and dirty as they are accessed from the mesh workers.
+	mu       sync.Mutex
+	unloaded bool
+
+	// dirty is set once the chunk has been edited and differs from both its generated and stored copies.
+	dirty bool
+
+	// world guards blocks, lightMap and the neighbours' copies of them while they are shared between
+	// goroutines. It is nil for chunks that are only used from one goroutine.
+	world *sync.RWMutex
+
+	Meshes    *ChunkMeshes
+	Mesher    Mesher
+	Generator TerrainGenerator
+	Pos       mgl64.Vec3
+	GridPos   mgl64.Vec3
 
-	XMinus, XPlus *Chunk
-	YMinus, YPlus *Chunk
-	ZMinus, ZPlus *Chunk
+	Neighbours
 
 	NumNeighbours int
 }
 
 func (c *Chunk) Setup() {
-	c.blocks = make([]BlockType, ChunkSizeCubed)
+	c.allocate()
+	c.generate()
+}
+
+func (c *Chunk) allocate() {
+	c.blocks = NewPaletteContainer(Empty)
 	c.lightMap = make([]byte, ChunkSizeCubed)
 
-	c.mesher = &CulledMesher{
-		Mesh:     c.Mesh,
-		Blocks:   c.blocks,
-		LightMap: c.lightMap,
-		Offset:   c.Pos,
-
-		XMinus: c.XMinus,
-		XPlus:  c.XPlus,
-		YMinus: c.YMinus,
-		YPlus:  c.YPlus,
-		ZMinus: c.ZMinus,
-		ZPlus:  c.ZPlus,
+	if c.Mesher == nil {
+		c.Mesher = NewCulledMesher()
 	}
+}
 
-	n1 := noise.NewCombined(noise.NewOctave(8), noise.NewOctave(8))
-	n2 := noise.NewCombined(noise.NewOctave(8), noise.NewOctave(8))
-	n3 := noise.NewOctave(6)
-
-	for x := 0.0; x < ChunkSize; x++ {
-		xPos := c.Pos.X() + x
-
-		for z := 0.0; z < ChunkSize; z++ {
-			zPos := c.Pos.Z() + z
-
-			hLow := n1.Compute(xPos*1.3, zPos*1.3)/6 - 4
-			height := hLow
+func (c *Chunk) generate() {
+	if c.Generator == nil {
+		c.Generator = NewHeightmapGenerator(DefaultHeightmapParams(0))
+	}
 
-			if n3.Compute(x, z) <= 0 {
-				hHigh := n2.Compute(xPos*1.3, zPos*1.3)/5 + 6
-				height = math.Max(hLow, hHigh)
-			}
+	c.Generator.Generate(c.blocks, c.Pos)
+}
 
-			height *= 0.5
-			if height < 0 {
-				height *= 0.8
-			}
+// Coord returns the chunk's position in the chunk grid.
+func (c *Chunk) Coord() ChunkCoord {
+	return ChunkCoord{int32(c.GridPos.X()), int32(c.GridPos.Y()), int32(c.GridPos.Z())}
+}
 
-			adjHeight := height // + water level
-			// TODO: cap this somehow
+func (c *Chunk) TearDown() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-			for y := 0.0; y < ChunkSize; y++ {
-				yPos := c.Pos.Y() + y
-				if yPos < adjHeight {
-					c.blocks.Set(int(x), int(y), int(z), Grass)
-				} else {
-					c.blocks.Set(int(x), int(y), int(z), Empty)
-				}
+	c.unloaded = true
 
-				//c.lightMap.SetSunlight()
-			}
-		}
+	if c.Meshes != nil {
+		c.Meshes.TearDown()
+		c.Meshes = nil
 	}
 }
 
-func (c *Chunk) TearDown() {
-	c.Mesh.TearDown()
+// PlaceTorch places a light source at the given position in the chunk. It returns every chunk whose
+// light map changed as a result.
+func (c *Chunk) PlaceTorch(x, y, z int) []*Chunk {
+	return placeTorchlight(LightNode{Chunk: c, X: x, Y: y, Z: z}, MaxLightLevel)
 }
 
-func (c *Chunk) PlaceTorch(x, y, z int) {
-	lightBfsQueue := list.New()
+// RemoveTorch removes the light source at the given position in the chunk. It returns every chunk
+// whose light map changed as a result.
+func (c *Chunk) RemoveTorch(x, y, z int) []*Chunk {
+	return removeTorchlight(LightNode{Chunk: c, X: x, Y: y, Z: z})
+}
 
-	c.lightMap.SetTorchlight(x, y, z, 15) // TODO: 16?
-	lightBfsQueue.PushBack(&LightNode{Chunk: c, X: x, Y: y, Z: z})
+// BuildMesh writes the chunk's geometry to the given meshes. Use swapMeshes to make them the chunk's
+// current meshes.
+func (c *Chunk) BuildMesh(meshes ChunkMeshes) {
+	if c.world != nil {
+		c.world.RLock()
+		defer c.world.RUnlock()
+	}
 
-	for lightBfsQueue.Len() > 0 {
-		e := lightBfsQueue.Front()
-		lightBfsQueue.Remove(e)
-		node := e.Value.(*LightNode)
+	c.Mesher.BuildMesh(meshes, c.blocks, c.lightMap, c.Pos, c.neighbours())
+}
 
-		lightLvl := node.Chunk.lightMap.Torchlight(node.X, node.Y, node.Z)
+// neighbours returns a copy of the chunk's neighbour links that is safe to use from any goroutine.
+func (c *Chunk) neighbours() Neighbours {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-		if node.X != 0 && c.blocks.Lookup(node.X-1, node.Y, node.Z) == Empty && node.Chunk.lightMap.Torchlight(node.X-1, node.Y, node.Z)+2 <= lightLvl {
-			node.Chunk.lightMap.SetTorchlight(node.X-1, node.Y, node.Z, lightLvl-1)
-			lightBfsQueue.PushBack(&LightNode{Chunk: node.Chunk, X: node.X - 1, Y: node.Y, Z: node.Z})
-		}
+	return c.Neighbours
+}
 
-		if node.Y != 0 && c.blocks.Lookup(node.X, node.Y-1, node.Z) == Empty && node.Chunk.lightMap.Torchlight(node.X, node.Y-1, node.Z)+2 <= lightLvl {
-			node.Chunk.lightMap.SetTorchlight(node.X, node.Y-1, node.Z, lightLvl-1)
-			lightBfsQueue.PushBack(&LightNode{Chunk: node.Chunk, X: node.X, Y: node.Y - 1, Z: node.Z})
-		}
+// swapMeshes replaces the chunk's meshes, tearing down the old ones. If the chunk has been unloaded
+// in the meantime the new meshes are torn down instead.
+func (c *Chunk) swapMeshes(meshes ChunkMeshes) {
+	c.mu.Lock()
+	old := c.Meshes
+	if c.unloaded {
+		old = &meshes
+	} else {
+		c.Meshes = &meshes
+	}
+	c.mu.Unlock()
 
-		if node.Z != 0 && c.blocks.Lookup(node.X, node.Y, node.Z-1) == Empty && node.Chunk.lightMap.Torchlight(node.X, node.Y, node.Z-1)+2 <= lightLvl {
-			node.Chunk.lightMap.SetTorchlight(node.X, node.Y, node.Z-1, lightLvl-1)
-			lightBfsQueue.PushBack(&LightNode{Chunk: node.Chunk, X: node.X, Y: node.Y, Z: node.Z - 1})
-		}
+	if old != nil {
+		old.TearDown()
+	}
+}
 
-		if node.X < ChunkSize && c.blocks.Lookup(node.X+1, node.Y, node.Z) == Empty && node.Chunk.lightMap.Torchlight(node.X+1, node.Y, node.Z)+2 <= lightLvl {
-			node.Chunk.lightMap.SetTorchlight(node.X+1, node.Y, node.Z, lightLvl-1)
-			lightBfsQueue.PushBack(&LightNode{Chunk: node.Chunk, X: node.X + 1, Y: node.Y, Z: node.Z})
-		}
+func (c *Chunk) markDirty() {
+	c.mu.Lock()
+	c.dirty = true
+	c.mu.Unlock()
+}
 
-		if node.Y < ChunkSize && c.blocks.Lookup(node.X, node.Y+1, node.Z) == Empty && node.Chunk.lightMap.Torchlight(node.X, node.Y+1, node.Z)+2 <= lightLvl {
-			node.Chunk.lightMap.SetTorchlight(node.X, node.Y+1, node.Z, lightLvl-1)
-			lightBfsQueue.PushBack(&LightNode{Chunk: node.Chunk, X: node.X, Y: node.Y + 1, Z: node.Z})
-		}
+// takeDirty reports whether the chunk has unsaved changes and clears the flag.
+func (c *Chunk) takeDirty() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-		if node.Z < ChunkSize && c.blocks.Lookup(node.X, node.Y, node.Z+1) == Empty && node.Chunk.lightMap.Torchlight(node.X, node.Y, node.Z+1)+2 <= lightLvl {
-			node.Chunk.lightMap.SetTorchlight(node.X, node.Y, node.Z+1, lightLvl-1)
-			lightBfsQueue.PushBack(&LightNode{Chunk: node.Chunk, X: node.X, Y: node.Y, Z: node.Z + 1})
-		}
-	}
+	dirty := c.dirty
+	c.dirty = false
 
+	return dirty
 }
 
-func (c *Chunk) BuildMesh() {
-	c.mesher.Update()
+func (c *Chunk) isUnloaded() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.unloaded
 }
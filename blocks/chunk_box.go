@@ -0,0 +1,126 @@
+package blocks
+
+import (
+	"time"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+// ChunkBox generates and lights every chunk in a box of the chunk grid without a ChunkManager, for
+// tools that build worlds offline.
+type ChunkBox struct {
+	Min, Max ChunkCoord
+
+	chunks *ChunkContainer
+}
+
+// GenerateChunkBox generates the chunks from min to max inclusive with g, decorates them with d if it
+// is not nil, and lights them as a ChunkManager would. Features that spill out of the box are dropped.
+// If timed is not nil it is called with how long each chunk took to generate and decorate.
+func GenerateChunkBox(min, max ChunkCoord, g TerrainGenerator, d *Decorator, timed func(coord ChunkCoord, took time.Duration)) *ChunkBox {
+	b := &ChunkBox{
+		Min:    min,
+		Max:    max,
+		chunks: NewChunkContainer(),
+	}
+
+	var outside []PlacedBlock
+
+	// Chunks are visited from the top down so that the chunk above is always lit before the one below.
+	b.each(func(c ChunkCoord) {
+		start := time.Now()
+
+		ch := &Chunk{
+			Generator: g,
+			Pos:       mgl64.Vec3{float64(c.X), float64(c.Y), float64(c.Z)}.Mul(ChunkSize * BlockSize),
+			GridPos:   mgl64.Vec3{float64(c.X), float64(c.Y), float64(c.Z)},
+		}
+
+		ch.Setup()
+
+		if d != nil {
+			outside = append(outside, d.Decorate(c, ch.blocks)...)
+		}
+
+		if timed != nil {
+			timed(c, time.Since(start))
+		}
+
+		b.chunks.Set(c, ch)
+	})
+
+	for _, p := range outside {
+		coord, x, y, z := worldToLocal(p.X, p.Y, p.Z)
+
+		if ch, ok := b.chunks.Lookup(coord); ok && ch.blocks.Lookup(x, y, z) == Empty {
+			ch.blocks.Set(x, y, z, p.Block)
+		}
+	}
+
+	b.each(func(c ChunkCoord) {
+		ch, _ := b.chunks.Lookup(c)
+		above, _ := b.chunks.Lookup(c.Offset(0, 1, 0))
+
+		seedSunlight(ch, above)
+	})
+
+	b.each(func(c ChunkCoord) {
+		ch, _ := b.chunks.Lookup(c)
+
+		if nch, ok := b.chunks.Lookup(c.Offset(1, 0, 0)); ok {
+			ch.XPlus, nch.XMinus = nch, ch
+			relightBorder(ch, nch)
+		}
+
+		if nch, ok := b.chunks.Lookup(c.Offset(0, 0, 1)); ok {
+			ch.ZPlus, nch.ZMinus = nch, ch
+			relightBorder(ch, nch)
+		}
+
+		if nch, ok := b.chunks.Lookup(c.Offset(0, -1, 0)); ok {
+			ch.YMinus, nch.YPlus = nch, ch
+			relightBorder(ch, nch)
+		}
+	})
+
+	return b
+}
+
+// each calls fn for every chunk coordinate in the box, from the top layer down.
+func (b *ChunkBox) each(fn func(c ChunkCoord)) {
+	for y := b.Max.Y; y >= b.Min.Y; y-- {
+		for x := b.Min.X; x <= b.Max.X; x++ {
+			for z := b.Min.Z; z <= b.Max.Z; z++ {
+				fn(ChunkCoord{x, y, z})
+			}
+		}
+	}
+}
+
+// BlockAt returns the block at the given world block coordinates, reporting false outside the box.
+func (b *ChunkBox) BlockAt(x, y, z int) (BlockType, bool) {
+	coord, lx, ly, lz := worldToLocal(x, y, z)
+
+	ch, ok := b.chunks.Lookup(coord)
+	if !ok {
+		return Empty, false
+	}
+
+	return ch.blocks.Lookup(lx, ly, lz), true
+}
+
+// Save writes every chunk in the box to s.
+func (b *ChunkBox) Save(s ChunkStore) error {
+	var err error
+
+	b.each(func(c ChunkCoord) {
+		if err != nil {
+			return
+		}
+
+		ch, _ := b.chunks.Lookup(c)
+		err = s.SaveChunk(c, ch.blocks, ch.lightMap)
+	})
+
+	return err
+}
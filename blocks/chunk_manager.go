@@ -1,25 +1,125 @@
 package blocks
 
 import (
+	"log"
+	"runtime"
+	"sync"
 	"time"
 
 	"github.com/go-gl/mathgl/mgl64"
 
 	"github.com/nickbryan/voxel/entity"
-	"github.com/nickbryan/voxel/renderer"
 )
 
 type ChunkManager struct {
-	Player   *entity.Player
-	Renderer *renderer.Renderer //TODO: interface this?
+	Player  *entity.Player
+	Backend RenderBackend
+
+	chunks      *ChunkContainer
+	newMesher   MesherFactory
+	generator   TerrainGenerator
+	decorator   *Decorator
+	meshWorkers *MeshWorkers
+	numWorkers  int
+	store       ChunkStore
+
+	// worldMu guards the blocks, light maps and neighbour links of every loaded chunk. Edits and light
+	// updates, which may spread across many chunks, hold it exclusively while meshing and lookups share
+	// it.
+	worldMu sync.RWMutex
+
+	// activeFluids holds the world block coordinates to check on the next fluid tick.
+	fluidMu      sync.Mutex
+	activeFluids map[[3]int]bool
+
+	// pending holds blocks placed by features in chunks that were not loaded at the time, keyed by
+	// chunk and then by world block coordinates. They are placed when the chunk loads. It holds at most
+	// maxPendingChunks chunks; dropped counts the blocks thrown away to keep it there.
+	pendingMu sync.Mutex
+	pending   map[ChunkCoord]map[[3]int]BlockType
+	dropped   int
+
+	// done is closed by Close to stop watchChunkLists, which marks watching done as it returns.
+	done      chan struct{}
+	closeOnce sync.Once
+	watching  sync.WaitGroup
+}
+
+// maxPendingChunks bounds how many chunks can have feature blocks waiting for them to load. A feature
+// only spills into the chunks beside its own, so the chunks around the player stay well under it, but
+// a player travelling in one direction leaves behind waiting blocks for chunks that never load.
+const maxPendingChunks = 1024
+
+// ChunkStore persists chunks between sessions. LoadChunk fills blocks and lightMap with the stored
+// copy of a chunk and reports false if there is none.
+type ChunkStore interface {
+	LoadChunk(coord ChunkCoord, blocks BlockStorage, lightMap LightMapContainer) (bool, error)
+	SaveChunk(coord ChunkCoord, blocks BlockStorage, lightMap LightMapContainer) error
+}
+
+// Option configures optional behaviour of a ChunkManager.
+type Option func(cm *ChunkManager)
 
-	chunks ChunkContainer
+// WithMesher sets the factory used to create the Mesher for each new chunk. Chunks use a
+// CulledMesher by default.
+func WithMesher(f MesherFactory) Option {
+	return func(cm *ChunkManager) {
+		cm.newMesher = f
+	}
+}
+
+// WithMeshWorkers sets the number of goroutines used to rebuild chunk meshes. It defaults to the
+// number of CPUs.
+func WithMeshWorkers(n int) Option {
+	return func(cm *ChunkManager) {
+		cm.numWorkers = n
+	}
 }
 
-func NewChunkManager(r *renderer.Renderer, p *entity.Player) *ChunkManager {
+// WithGenerator sets the generator used to fill new chunks that are not in the store. Chunks use a
+// HeightmapGenerator with the default parameters and a seed of 0 by default.
+func WithGenerator(g TerrainGenerator) Option {
+	return func(cm *ChunkManager) {
+		cm.generator = g
+	}
+}
+
+// WithDecorator sets the decorator used to place features on newly generated chunks. Chunks are not
+// decorated by default.
+//
+// Features can spill into neighbouring chunks. Blocks for a chunk that is not loaded are held until it
+// loads, for up to 1024 chunks; past that the blocks for the chunk farthest from the player are
+// dropped, and counted by DroppedFeatureBlocks, so parts of features can go missing after long
+// journeys. Spilled blocks are not regenerated: without a store, a chunk that unloads and is
+// generated again only gets them back if the chunk the feature grew from is generated again too.
+func WithDecorator(d *Decorator) Option {
+	return func(cm *ChunkManager) {
+		cm.decorator = d
+	}
+}
+
+// WithStore sets where chunks are loaded from and edited chunks are saved to. Without a store every
+// chunk is generated and edits are lost when it unloads.
+func WithStore(s ChunkStore) Option {
+	return func(cm *ChunkManager) {
+		cm.store = s
+	}
+}
+
+func NewChunkManager(b RenderBackend, p *entity.Player, opts ...Option) *ChunkManager {
 	cm := &ChunkManager{
-		Renderer: r,
-		Player:   p,
+		Backend:    b,
+		Player:     p,
+		newMesher:  NewCulledMesher,
+		generator:  NewHeightmapGenerator(DefaultHeightmapParams(0)),
+		numWorkers: runtime.NumCPU(),
+
+		activeFluids: make(map[[3]int]bool),
+		pending:      make(map[ChunkCoord]map[[3]int]BlockType),
+	}
+
+	for _, opt := range opts {
+		opt(cm)
 	}
 
 	cm.Setup()
@@ -28,165 +128,195 @@ func NewChunkManager(r *renderer.Renderer, p *entity.Player) *ChunkManager {
 }
 
 func (cm *ChunkManager) Setup() {
-	cm.chunks = make(map[string]*Chunk)
+	cm.chunks = NewChunkContainer()
+	cm.meshWorkers = NewMeshWorkers(cm.numWorkers, func() ChunkMeshes {
+		return ChunkMeshes{
+			Opaque:      cm.Backend.CreateMesh(),
+			Transparent: cm.Backend.CreateTransparentMesh(),
+		}
+	})
+
+	cm.done = make(chan struct{})
 
 	cm.newChunk(0, 0, 0)
+
+	cm.watching.Add(1)
 	go cm.watchChunkLists()
 }
 
+// Close stops loading and unloading chunks around the player and stops the mesh workers, waiting for
+// any rebuild in progress. Loaded chunks stay readable; call Save first to keep edits.
+func (cm *ChunkManager) Close() {
+	cm.closeOnce.Do(func() {
+		close(cm.done)
+		cm.watching.Wait()
+		cm.meshWorkers.Stop()
+	})
+}
+
 func (cm *ChunkManager) watchChunkLists() {
+	defer cm.watching.Done()
+
 	t := time.NewTicker(time.Millisecond * 100)
 	defer t.Stop()
 
+	for {
+		select {
+		case <-cm.done:
+			return
+		case <-t.C:
+			cm.updateChunks()
+		}
+	}
+}
+
+// updateChunks unloads the chunks that are too far from the player and loads the missing neighbours of
+// the chunks that remain.
+func (cm *ChunkManager) updateChunks() {
 	drawDistance := float64(4 * ChunkSize)
-	for range t.C {
-		for _, ch := range cm.chunks {
-			if ch.NumNeighbours == 6 {
+
+	for _, ch := range cm.chunks.Snapshot() {
+		if ch.NumNeighbours == 6 || ch.isUnloaded() {
+			continue
+		}
+
+		{
+			xPos := ch.GridPos.X() * ChunkSize * BlockSize
+			yPos := ch.GridPos.Y() * ChunkSize * BlockSize
+			zPos := ch.GridPos.Z() * ChunkSize * BlockSize
+			lenHlf := float64(ChunkSize * BlockRenderSize)
+			cntr := mgl64.Vec3{xPos, yPos, zPos}.Add(mgl64.Vec3{lenHlf, lenHlf, lenHlf})
+			distVec := cntr.Sub(mgl64.Vec3{float64(cm.Player.Pos().X()), float64(cm.Player.Pos().Y()), float64(cm.Player.Pos().Z())})
+
+			if distVec.Len() > drawDistance {
+				cm.unloadChunk(ch)
 				continue
 			}
+		}
 
-			{
-				xPos := ch.GridPos.X() * ChunkSize * BlockSize
-				yPos := ch.GridPos.Y() * ChunkSize * BlockSize
-				zPos := ch.GridPos.Z() * ChunkSize * BlockSize
-				lenHlf := float64(ChunkSize * BlockRenderSize)
-				cntr := mgl64.Vec3{xPos, yPos, zPos}.Add(mgl64.Vec3{lenHlf, lenHlf, lenHlf})
-				distVec := cntr.Sub(mgl64.Vec3{float64(cm.Player.Pos().X()), float64(cm.Player.Pos().Y()), float64(cm.Player.Pos().Z())})
-
-				if distVec.Len() > drawDistance {
-					cm.unloadChunk(ch)
-					continue
+		if ch.XPlus == nil {
+			xPos := (ch.GridPos.X() + 1) * ChunkSize * BlockSize
+			yPos := ch.GridPos.Y() * ChunkSize * BlockSize
+			zPos := ch.GridPos.Z() * ChunkSize * BlockSize
+			lenHlf := float64(ChunkSize * BlockRenderSize)
+			cntr := mgl64.Vec3{xPos, yPos, zPos}.Add(mgl64.Vec3{lenHlf, lenHlf, lenHlf})
+			distVec := cntr.Sub(mgl64.Vec3{float64(cm.Player.Pos().X()), float64(cm.Player.Pos().Y()), float64(cm.Player.Pos().Z())})
+
+			if distVec.Len() <= drawDistance {
+				nch, ok := cm.chunks.Lookup(ch.Coord().Offset(1, 0, 0))
+				if !ok {
+					nch = cm.newChunk(ch.GridPos.X()+1, ch.GridPos.Y(), ch.GridPos.Z())
 				}
-			}
-
-			if ch.XPlus == nil {
-				xPos := (ch.GridPos.X() + 1) * ChunkSize * BlockSize
-				yPos := ch.GridPos.Y() * ChunkSize * BlockSize
-				zPos := ch.GridPos.Z() * ChunkSize * BlockSize
-				lenHlf := float64(ChunkSize * BlockRenderSize)
-				cntr := mgl64.Vec3{xPos, yPos, zPos}.Add(mgl64.Vec3{lenHlf, lenHlf, lenHlf})
-				distVec := cntr.Sub(mgl64.Vec3{float64(cm.Player.Pos().X()), float64(cm.Player.Pos().Y()), float64(cm.Player.Pos().Z())})
-
-				if distVec.Len() <= drawDistance {
-					nch, ok := cm.chunks.Lookup(int(ch.GridPos.X()+1), int(ch.GridPos.Y()), int(ch.GridPos.Z()))
-					if !ok {
-						nch = cm.newChunk(ch.GridPos.X()+1, ch.GridPos.Y(), ch.GridPos.Z())
-					}
 
+				cm.link(ch, nch, func() {
 					ch.XPlus = nch
 					nch.XMinus = ch
-					ch.NumNeighbours += 1
-					nch.NumNeighbours += 1
-					// TODO: When we update the neighbours we need to update the meshes.
-					// TODO: We will need to clear the current mesh (possibly call teadown but i dont know what happens when deleting from the card)
-					// TODO: Then we will need to gen a FRESH mesh
-					// TODO: We can possible swap the current mesh to a cached mesh then create a new mesh then delete the old mesh and swap if we get artifacts in rendering
-				}
+				})
 			}
+		}
 
-			if ch.XMinus == nil {
-				xPos := (ch.GridPos.X() - 1) * ChunkSize * BlockSize
-				yPos := ch.GridPos.Y() * ChunkSize * BlockSize
-				zPos := ch.GridPos.Z() * ChunkSize * BlockSize
-				lenHlf := float64(ChunkSize * BlockRenderSize)
-				cntr := mgl64.Vec3{xPos, yPos, zPos}.Add(mgl64.Vec3{lenHlf, lenHlf, lenHlf})
-				distVec := cntr.Sub(mgl64.Vec3{float64(cm.Player.Pos().X()), float64(cm.Player.Pos().Y()), float64(cm.Player.Pos().Z())})
-
-				if distVec.Len() <= drawDistance {
-					nch, ok := cm.chunks.Lookup(int(ch.GridPos.X()-1), int(ch.GridPos.Y()), int(ch.GridPos.Z()))
-					if !ok {
-						nch = cm.newChunk(ch.GridPos.X()-1, ch.GridPos.Y(), ch.GridPos.Z())
-					}
+		if ch.XMinus == nil {
+			xPos := (ch.GridPos.X() - 1) * ChunkSize * BlockSize
+			yPos := ch.GridPos.Y() * ChunkSize * BlockSize
+			zPos := ch.GridPos.Z() * ChunkSize * BlockSize
+			lenHlf := float64(ChunkSize * BlockRenderSize)
+			cntr := mgl64.Vec3{xPos, yPos, zPos}.Add(mgl64.Vec3{lenHlf, lenHlf, lenHlf})
+			distVec := cntr.Sub(mgl64.Vec3{float64(cm.Player.Pos().X()), float64(cm.Player.Pos().Y()), float64(cm.Player.Pos().Z())})
+
+			if distVec.Len() <= drawDistance {
+				nch, ok := cm.chunks.Lookup(ch.Coord().Offset(-1, 0, 0))
+				if !ok {
+					nch = cm.newChunk(ch.GridPos.X()-1, ch.GridPos.Y(), ch.GridPos.Z())
+				}
 
+				cm.link(ch, nch, func() {
 					ch.XMinus = nch
 					nch.XPlus = ch
-					ch.NumNeighbours += 1
-					nch.NumNeighbours += 1
-				}
+				})
 			}
+		}
 
-			if ch.ZPlus == nil {
-				xPos := ch.GridPos.X() * ChunkSize * BlockSize
-				yPos := ch.GridPos.Y() * ChunkSize * BlockSize
-				zPos := (ch.GridPos.Z() + 1) * ChunkSize * BlockSize
-				lenHlf := float64(ChunkSize * BlockRenderSize)
-				cntr := mgl64.Vec3{xPos, yPos, zPos}.Add(mgl64.Vec3{lenHlf, lenHlf, lenHlf})
-				distVec := cntr.Sub(mgl64.Vec3{float64(cm.Player.Pos().X()), float64(cm.Player.Pos().Y()), float64(cm.Player.Pos().Z())})
-
-				if distVec.Len() <= drawDistance {
-					nch, ok := cm.chunks.Lookup(int(ch.GridPos.X()), int(ch.GridPos.Y()), int(ch.GridPos.Z()+1))
-					if !ok {
-						nch = cm.newChunk(ch.GridPos.X(), ch.GridPos.Y(), ch.GridPos.Z()+1)
-					}
+		if ch.ZPlus == nil {
+			xPos := ch.GridPos.X() * ChunkSize * BlockSize
+			yPos := ch.GridPos.Y() * ChunkSize * BlockSize
+			zPos := (ch.GridPos.Z() + 1) * ChunkSize * BlockSize
+			lenHlf := float64(ChunkSize * BlockRenderSize)
+			cntr := mgl64.Vec3{xPos, yPos, zPos}.Add(mgl64.Vec3{lenHlf, lenHlf, lenHlf})
+			distVec := cntr.Sub(mgl64.Vec3{float64(cm.Player.Pos().X()), float64(cm.Player.Pos().Y()), float64(cm.Player.Pos().Z())})
+
+			if distVec.Len() <= drawDistance {
+				nch, ok := cm.chunks.Lookup(ch.Coord().Offset(0, 0, 1))
+				if !ok {
+					nch = cm.newChunk(ch.GridPos.X(), ch.GridPos.Y(), ch.GridPos.Z()+1)
+				}
 
+				cm.link(ch, nch, func() {
 					ch.ZPlus = nch
 					nch.ZMinus = ch
-					ch.NumNeighbours += 1
-					nch.NumNeighbours += 1
-				}
+				})
 			}
+		}
 
-			if ch.ZMinus == nil {
-				xPos := ch.GridPos.X() * ChunkSize * BlockSize
-				yPos := ch.GridPos.Y() * ChunkSize * BlockSize
-				zPos := (ch.GridPos.Z() - 1) * ChunkSize * BlockSize
-				lenHlf := float64(ChunkSize * BlockRenderSize)
-				cntr := mgl64.Vec3{xPos, yPos, zPos}.Add(mgl64.Vec3{lenHlf, lenHlf, lenHlf})
-				distVec := cntr.Sub(mgl64.Vec3{float64(cm.Player.Pos().X()), float64(cm.Player.Pos().Y()), float64(cm.Player.Pos().Z())})
-
-				if distVec.Len() <= drawDistance {
-					nch, ok := cm.chunks.Lookup(int(ch.GridPos.X()), int(ch.GridPos.Y()), int(ch.GridPos.Z()-1))
-					if !ok {
-						nch = cm.newChunk(ch.GridPos.X(), ch.GridPos.Y(), ch.GridPos.Z()-1)
-					}
+		if ch.ZMinus == nil {
+			xPos := ch.GridPos.X() * ChunkSize * BlockSize
+			yPos := ch.GridPos.Y() * ChunkSize * BlockSize
+			zPos := (ch.GridPos.Z() - 1) * ChunkSize * BlockSize
+			lenHlf := float64(ChunkSize * BlockRenderSize)
+			cntr := mgl64.Vec3{xPos, yPos, zPos}.Add(mgl64.Vec3{lenHlf, lenHlf, lenHlf})
+			distVec := cntr.Sub(mgl64.Vec3{float64(cm.Player.Pos().X()), float64(cm.Player.Pos().Y()), float64(cm.Player.Pos().Z())})
+
+			if distVec.Len() <= drawDistance {
+				nch, ok := cm.chunks.Lookup(ch.Coord().Offset(0, 0, -1))
+				if !ok {
+					nch = cm.newChunk(ch.GridPos.X(), ch.GridPos.Y(), ch.GridPos.Z()-1)
+				}
 
+				cm.link(ch, nch, func() {
 					ch.ZMinus = nch
 					nch.ZPlus = ch
-					ch.NumNeighbours += 1
-					nch.NumNeighbours += 1
-				}
+				})
 			}
+		}
 
-			if ch.YPlus == nil {
-				xPos := ch.GridPos.X() * ChunkSize * BlockSize
-				yPos := (ch.GridPos.Y() + 1) * ChunkSize * BlockSize
-				zPos := ch.GridPos.Z() * ChunkSize * BlockSize
-				lenHlf := float64(ChunkSize * BlockRenderSize)
-				cntr := mgl64.Vec3{xPos, yPos, zPos}.Add(mgl64.Vec3{lenHlf, lenHlf, lenHlf})
-				distVec := cntr.Sub(mgl64.Vec3{float64(cm.Player.Pos().X()), float64(cm.Player.Pos().Y()), float64(cm.Player.Pos().Z())})
-
-				if distVec.Len() <= drawDistance {
-					nch, ok := cm.chunks.Lookup(int(ch.GridPos.X()), int(ch.GridPos.Y()+1), int(ch.GridPos.Z()))
-					if !ok {
-						nch = cm.newChunk(ch.GridPos.X(), ch.GridPos.Y()+1, ch.GridPos.Z())
-					}
+		if ch.YPlus == nil {
+			xPos := ch.GridPos.X() * ChunkSize * BlockSize
+			yPos := (ch.GridPos.Y() + 1) * ChunkSize * BlockSize
+			zPos := ch.GridPos.Z() * ChunkSize * BlockSize
+			lenHlf := float64(ChunkSize * BlockRenderSize)
+			cntr := mgl64.Vec3{xPos, yPos, zPos}.Add(mgl64.Vec3{lenHlf, lenHlf, lenHlf})
+			distVec := cntr.Sub(mgl64.Vec3{float64(cm.Player.Pos().X()), float64(cm.Player.Pos().Y()), float64(cm.Player.Pos().Z())})
+
+			if distVec.Len() <= drawDistance {
+				nch, ok := cm.chunks.Lookup(ch.Coord().Offset(0, 1, 0))
+				if !ok {
+					nch = cm.newChunk(ch.GridPos.X(), ch.GridPos.Y()+1, ch.GridPos.Z())
+				}
 
+				cm.link(ch, nch, func() {
 					ch.YPlus = nch
 					nch.YMinus = ch
-					ch.NumNeighbours += 1
-					nch.NumNeighbours += 1
-				}
+				})
 			}
+		}
 
-			if ch.YMinus == nil {
-				xPos := ch.GridPos.X() * ChunkSize * BlockSize
-				yPos := (ch.GridPos.Y() - 1) * ChunkSize * BlockSize
-				zPos := ch.GridPos.Z() * ChunkSize * BlockSize
-				lenHlf := float64(ChunkSize * BlockRenderSize)
-				cntr := mgl64.Vec3{xPos, yPos, zPos}.Add(mgl64.Vec3{lenHlf, lenHlf, lenHlf})
-				distVec := cntr.Sub(mgl64.Vec3{float64(cm.Player.Pos().X()), float64(cm.Player.Pos().Y()), float64(cm.Player.Pos().Z())})
-
-				if distVec.Len() <= drawDistance {
-					nch, ok := cm.chunks.Lookup(int(ch.GridPos.X()), int(ch.GridPos.Y()-1), int(ch.GridPos.Z()))
-					if !ok {
-						nch = cm.newChunk(ch.GridPos.X(), ch.GridPos.Y()-1, ch.GridPos.Z())
-					}
+		if ch.YMinus == nil {
+			xPos := ch.GridPos.X() * ChunkSize * BlockSize
+			yPos := (ch.GridPos.Y() - 1) * ChunkSize * BlockSize
+			zPos := ch.GridPos.Z() * ChunkSize * BlockSize
+			lenHlf := float64(ChunkSize * BlockRenderSize)
+			cntr := mgl64.Vec3{xPos, yPos, zPos}.Add(mgl64.Vec3{lenHlf, lenHlf, lenHlf})
+			distVec := cntr.Sub(mgl64.Vec3{float64(cm.Player.Pos().X()), float64(cm.Player.Pos().Y()), float64(cm.Player.Pos().Z())})
+
+			if distVec.Len() <= drawDistance {
+				nch, ok := cm.chunks.Lookup(ch.Coord().Offset(0, -1, 0))
+				if !ok {
+					nch = cm.newChunk(ch.GridPos.X(), ch.GridPos.Y()-1, ch.GridPos.Z())
+				}
 
+				cm.link(ch, nch, func() {
 					ch.YMinus = nch
 					nch.YPlus = ch
-					ch.NumNeighbours += 1
-					nch.NumNeighbours += 1
-				}
+				})
 			}
 		}
 	}
@@ -198,64 +328,267 @@ func (cm *ChunkManager) newChunk(x, y, z float64) *Chunk {
 	zPos := z * ChunkSize * BlockSize
 
 	ch := &Chunk{
-		Mesh:    cm.Renderer.CreateMesh(), // TODO: can we re use this mesh by passing it to all other chunks?
-		Pos:     mgl64.Vec3{xPos, yPos, zPos},
-		GridPos: mgl64.Vec3{x, y, z},
+		Mesher:    cm.newMesher(),
+		Generator: cm.generator,
+		world:     &cm.worldMu,
+		Pos:       mgl64.Vec3{xPos, yPos, zPos},
+		GridPos:   mgl64.Vec3{x, y, z},
+	}
+
+	// Fill the chunk before storing it so that it is never visible to BlockAt without its blocks.
+	ch.allocate()
+
+	var outside []PlacedBlock
+
+	if !cm.loadChunk(ch) {
+		ch.generate()
+
+		if cm.decorator != nil {
+			outside = cm.decorator.Decorate(ch.Coord(), ch.blocks)
+		}
+
+		above, _ := cm.chunks.Lookup(ch.Coord().Offset(0, 1, 0))
+
+		cm.worldMu.Lock()
+		seedSunlight(ch, above)
+		cm.worldMu.Unlock()
 	}
 
-	cm.chunks.Set(int(x), int(y), int(z), ch)
+	cm.chunks.Set(ch.Coord(), ch)
+	cm.meshWorkers.Enqueue(ch)
 
-	ch.Setup()
-	ch.BuildMesh()
-	ch.Mesh.Finish()
+	cm.placeBlocks(outside)
+	cm.placeBlocks(cm.takePending(ch.Coord()))
 
 	return ch
 }
 
+// placeBlocks places blocks from features into the empty blocks of loaded chunks, and saves the rest
+// until their chunk loads.
+func (cm *ChunkManager) placeBlocks(placed []PlacedBlock) {
+	for _, p := range placed {
+		bt, ok := cm.BlockAt(p.X, p.Y, p.Z)
+		if ok {
+			if bt == Empty {
+				cm.SetBlock(p.X, p.Y, p.Z, p.Block)
+			}
+			continue
+		}
+
+		coord, _, _, _ := worldToLocal(p.X, p.Y, p.Z)
+
+		cm.pendingMu.Lock()
+		if cm.pending[coord] == nil {
+			cm.pending[coord] = make(map[[3]int]BlockType)
+		}
+		cm.pending[coord][[3]int{p.X, p.Y, p.Z}] = p.Block
+
+		if len(cm.pending) > maxPendingChunks {
+			farthest := cm.farthestPending()
+
+			if cm.dropped == 0 {
+				log.Printf("more than %d chunks have feature blocks waiting to load, dropping the farthest", maxPendingChunks)
+			}
+			cm.dropped += len(cm.pending[farthest])
+
+			delete(cm.pending, farthest)
+		}
+		cm.pendingMu.Unlock()
+	}
+}
+
+// DroppedFeatureBlocks returns the number of feature blocks dropped because too many unloaded chunks
+// had blocks waiting for them. See WithDecorator.
+func (cm *ChunkManager) DroppedFeatureBlocks() int {
+	cm.pendingMu.Lock()
+	defer cm.pendingMu.Unlock()
+
+	return cm.dropped
+}
+
+// farthestPending returns the chunk in pending that is farthest from the player, and so least likely to
+// load. pendingMu must be held.
+func (cm *ChunkManager) farthestPending() ChunkCoord {
+	pos := cm.Player.Pos()
+	player := mgl64.Vec3{float64(pos.X()), float64(pos.Y()), float64(pos.Z())}
+	lenHlf := float64(ChunkSize * BlockRenderSize)
+
+	var farthest ChunkCoord
+	maxDist := -1.0
+
+	for coord := range cm.pending {
+		cntr := mgl64.Vec3{float64(coord.X), float64(coord.Y), float64(coord.Z)}.
+			Mul(ChunkSize * BlockSize).
+			Add(mgl64.Vec3{lenHlf, lenHlf, lenHlf})
+
+		if dist := cntr.Sub(player).Len(); dist > maxDist {
+			farthest, maxDist = coord, dist
+		}
+	}
+
+	return farthest
+}
+
+// takePending removes and returns the blocks waiting to be placed in the chunk at coord.
+func (cm *ChunkManager) takePending(coord ChunkCoord) []PlacedBlock {
+	cm.pendingMu.Lock()
+	defer cm.pendingMu.Unlock()
+
+	var placed []PlacedBlock
+	for p, bt := range cm.pending[coord] {
+		placed = append(placed, PlacedBlock{p[0], p[1], p[2], bt})
+	}
+	delete(cm.pending, coord)
+
+	return placed
+}
+
+// link joins two neighbouring chunks using set, spreads light across their shared border and queues
+// both for a mesh rebuild so that the faces along the border are updated.
+func (cm *ChunkManager) link(ch, nch *Chunk, set func()) {
+	cm.worldMu.Lock()
+
+	ch.mu.Lock()
+	nch.mu.Lock()
+
+	set()
+	ch.NumNeighbours += 1
+	nch.NumNeighbours += 1
+
+	nch.mu.Unlock()
+	ch.mu.Unlock()
+
+	changed := relightBorder(ch, nch)
+	cm.worldMu.Unlock()
+
+	cm.meshWorkers.Enqueue(ch)
+	cm.meshWorkers.Enqueue(nch)
+
+	for _, c := range changed {
+		cm.meshWorkers.Enqueue(c)
+	}
+}
+
+// unlink removes a neighbour from ch using unset and queues ch for a mesh rebuild.
+func (cm *ChunkManager) unlink(ch *Chunk, unset func()) {
+	cm.worldMu.Lock()
+	ch.mu.Lock()
+
+	unset()
+	ch.NumNeighbours -= 1
+
+	ch.mu.Unlock()
+	cm.worldMu.Unlock()
+
+	cm.meshWorkers.Enqueue(ch)
+}
+
 func (cm *ChunkManager) unloadChunk(ch *Chunk) {
-	if nch, ok := cm.chunks.Lookup(int(ch.GridPos.X()+1), int(ch.GridPos.Y()), int(ch.GridPos.Z())); ok {
+	if nch, ok := cm.chunks.Lookup(ch.Coord().Offset(1, 0, 0)); ok {
 		if nch.XMinus != nil {
-			nch.NumNeighbours -= 1
-			nch.XMinus = nil
+			cm.unlink(nch, func() {
+				nch.XMinus = nil
+			})
 		}
 	}
 
-	if nch, ok := cm.chunks.Lookup(int(ch.GridPos.X()-1), int(ch.GridPos.Y()), int(ch.GridPos.Z())); ok {
+	if nch, ok := cm.chunks.Lookup(ch.Coord().Offset(-1, 0, 0)); ok {
 		if nch.XPlus != nil {
-			nch.NumNeighbours -= 1
-			nch.XPlus = nil
+			cm.unlink(nch, func() {
+				nch.XPlus = nil
+			})
 		}
 	}
 
-	if nch, ok := cm.chunks.Lookup(int(ch.GridPos.X()), int(ch.GridPos.Y()), int(ch.GridPos.Z()+1)); ok {
+	if nch, ok := cm.chunks.Lookup(ch.Coord().Offset(0, 0, 1)); ok {
 		if nch.ZMinus != nil {
-			nch.NumNeighbours -= 1
-			nch.ZMinus = nil
+			cm.unlink(nch, func() {
+				nch.ZMinus = nil
+			})
 		}
 	}
 
-	if nch, ok := cm.chunks.Lookup(int(ch.GridPos.X()), int(ch.GridPos.Y()), int(ch.GridPos.Z()-1)); ok {
+	if nch, ok := cm.chunks.Lookup(ch.Coord().Offset(0, 0, -1)); ok {
 		if nch.ZPlus != nil {
-			nch.NumNeighbours -= 1
-			nch.ZPlus = nil
+			cm.unlink(nch, func() {
+				nch.ZPlus = nil
+			})
 		}
 	}
 
-	if nch, ok := cm.chunks.Lookup(int(ch.GridPos.X()), int(ch.GridPos.Y()+1), int(ch.GridPos.Z())); ok {
+	if nch, ok := cm.chunks.Lookup(ch.Coord().Offset(0, 1, 0)); ok {
 		if nch.YMinus != nil {
-			nch.NumNeighbours -= 1
-			nch.YMinus = nil
+			cm.unlink(nch, func() {
+				nch.YMinus = nil
+			})
 		}
 	}
 
-	if nch, ok := cm.chunks.Lookup(int(ch.GridPos.X()), int(ch.GridPos.Y()-1), int(ch.GridPos.Z())); ok {
+	if nch, ok := cm.chunks.Lookup(ch.Coord().Offset(0, -1, 0)); ok {
 		if nch.YPlus != nil {
-			nch.NumNeighbours -= 1
-			nch.YPlus = nil
+			cm.unlink(nch, func() {
+				nch.YPlus = nil
+			})
 		}
 	}
 
 	ch.TearDown()
-	cm.chunks.Unset(int(ch.GridPos.X()), int(ch.GridPos.Y()), int(ch.GridPos.Z()))
+	cm.chunks.Unset(ch.Coord())
+
+	if err := cm.saveChunk(ch); err != nil {
+		log.Printf("saving chunk %v: %v", ch.Coord(), err)
+	}
+
 	ch = nil
 }
+
+// Save writes every loaded chunk with unsaved changes to the store.
+func (cm *ChunkManager) Save() error {
+	for _, ch := range cm.chunks.Snapshot() {
+		if err := cm.saveChunk(ch); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// loadChunk fills the chunk from the store, reporting false if it has to be generated instead.
+func (cm *ChunkManager) loadChunk(ch *Chunk) bool {
+	if cm.store == nil {
+		return false
+	}
+
+	ok, err := cm.store.LoadChunk(ch.Coord(), ch.blocks, ch.lightMap)
+	if err != nil {
+		log.Printf("loading chunk %v: %v", ch.Coord(), err)
+
+		// The record may have been partly decoded into the chunk before the error, so start again from
+		// nothing before it is generated.
+		ch.allocate()
+		return false
+	}
+
+	return ok
+}
+
+// saveChunk writes the chunk to the store if it has changed since it was loaded or generated.
+func (cm *ChunkManager) saveChunk(ch *Chunk) error {
+	if cm.store == nil || !ch.takeDirty() {
+		return nil
+	}
+
+	// Copy under the light lock so that a light update spreading through the chunk is not saved half
+	// done, then write the copy without holding up other updates.
+	cm.worldMu.RLock()
+	bc := ch.blocks.Clone()
+	lm := append(LightMapContainer(nil), ch.lightMap...)
+	cm.worldMu.RUnlock()
+
+	if err := cm.store.SaveChunk(ch.Coord(), bc, lm); err != nil {
+		ch.markDirty()
+		return err
+	}
+
+	return nil
+}
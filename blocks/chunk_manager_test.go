@@ -0,0 +1,240 @@
+package blocks
+
+import (
+	"errors"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nickbryan/voxel/entity"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+// countingBackend is a RenderBackend that counts the meshes built and torn down.
+type countingBackend struct {
+	created, finished, tornDown int64
+}
+
+type countingMesh struct {
+	b        *countingBackend
+	vertices int
+}
+
+func (b *countingBackend) CreateMesh() MeshRenderer {
+	atomic.AddInt64(&b.created, 1)
+	return &countingMesh{b: b}
+}
+
+func (b *countingBackend) CreateTransparentMesh() MeshRenderer {
+	return b.CreateMesh()
+}
+
+func (m *countingMesh) AddVertex(mgl64.Vec3) uint32 {
+	m.vertices++
+	return uint32(m.vertices - 1)
+}
+
+func (m *countingMesh) AddTriangle(v1, v2, v3 uint32) {}
+func (m *countingMesh) SetColor(mgl64.Vec3)           {}
+func (m *countingMesh) SetAlpha(float64)              {}
+func (m *countingMesh) SetLight(uint8, uint8)         {}
+
+func (m *countingMesh) Finish() {
+	if m.vertices > 0 {
+		atomic.AddInt64(&m.b.finished, 1)
+	}
+}
+
+func (m *countingMesh) TearDown() {
+	atomic.AddInt64(&m.b.tornDown, 1)
+}
+
+// flatGenerator fills every block below y=0 with stone.
+type flatGenerator struct{}
+
+func (flatGenerator) Generate(bs BlockStorage, pos mgl64.Vec3) {
+	for y := 0; y < ChunkSize; y++ {
+		if int(pos.Y())+y >= 0 {
+			break
+		}
+
+		for x := 0; x < ChunkSize; x++ {
+			for z := 0; z < ChunkSize; z++ {
+				bs.Set(x, y, z, Stone)
+			}
+		}
+	}
+}
+
+// waitFor polls cond until it holds or a few seconds pass.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	for deadline := time.Now().Add(5 * time.Second); !cond(); {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestChunkManagerLifecycle(t *testing.T) {
+	goroutines := runtime.NumGoroutine()
+
+	b := &countingBackend{}
+	p := entity.NewPlayer()
+	cm := NewChunkManager(b, p, WithGenerator(flatGenerator{}), WithMeshWorkers(2))
+
+	// The player starts in chunk 0, 0, 0 so the chunk below it is among the first to load.
+	waitFor(t, "the chunk below the player to load", func() bool {
+		_, ok := cm.BlockAt(3, -1, 3)
+		return ok
+	})
+
+	waitFor(t, "chunk meshes to be built", func() bool {
+		return atomic.LoadInt64(&b.finished) > 0
+	})
+
+	if bt, ok := cm.BlockAt(3, -1, 3); !ok || bt != Stone {
+		t.Errorf("BlockAt(3, -1, 3) = %d, %v; want stone", bt, ok)
+	}
+
+	if bt, ok := cm.BlockAt(3, 0, 3); !ok || bt != Empty {
+		t.Errorf("BlockAt(3, 0, 3) = %d, %v; want empty", bt, ok)
+	}
+
+	if !cm.SetBlock(3, 0, 3, Log) {
+		t.Fatal("SetBlock in a loaded chunk reported it was not loaded")
+	}
+
+	if bt, _ := cm.BlockAt(3, 0, 3); bt != Log {
+		t.Errorf("BlockAt after SetBlock = %d, want log", bt)
+	}
+
+	if _, _, ok := cm.LightAt(100000, 0, 0); ok {
+		t.Error("LightAt reported a chunk far from the player as loaded")
+	}
+
+	cm.Close()
+	cm.Close()
+
+	waitFor(t, "the chunk manager's goroutines to exit", func() bool {
+		return runtime.NumGoroutine() <= goroutines
+	})
+
+	loaded := cm.chunks.Len()
+	if loaded == 0 {
+		t.Fatal("no chunks loaded")
+	}
+
+	// With the watcher stopped the player can be moved safely and the chunks updated by hand. Chunks
+	// with a neighbour on every side are only unloaded once their neighbours have gone, so it takes a
+	// pass per layer of chunks.
+	for p.Pos().Y() < 1000 {
+		p.Climb(1)
+	}
+
+	for i := 0; i < 20 && cm.chunks.Len() > 0; i++ {
+		cm.updateChunks()
+	}
+
+	if n := cm.chunks.Len(); n != 0 {
+		t.Errorf("%d chunks still loaded after the player moved away", n)
+	}
+
+	if _, ok := cm.BlockAt(3, 0, 3); ok {
+		t.Error("BlockAt reported an unloaded chunk as loaded")
+	}
+
+	// Every loaded chunk had its opaque and transparent meshes torn down as it unloaded.
+	if got := atomic.LoadInt64(&b.tornDown); got < int64(2*loaded) {
+		t.Errorf("%d meshes torn down after unloading %d chunks, want at least %d", got, loaded, 2*loaded)
+	}
+}
+
+func TestPendingBlocksBounded(t *testing.T) {
+	cm := &ChunkManager{
+		Player:  entity.NewPlayer(),
+		chunks:  NewChunkContainer(),
+		pending: make(map[ChunkCoord]map[[3]int]BlockType),
+	}
+
+	// One block in each chunk along a line heading away from the player, none of them loaded.
+	var placed []PlacedBlock
+	for i := 0; i < maxPendingChunks+10; i++ {
+		placed = append(placed, PlacedBlock{X: i * ChunkSize, Y: 0, Z: 0, Block: Leaves})
+	}
+
+	cm.placeBlocks(placed)
+
+	if n := len(cm.pending); n != maxPendingChunks {
+		t.Fatalf("%d chunks have pending blocks, want %d", n, maxPendingChunks)
+	}
+
+	if got := cm.takePending(ChunkCoord{}); len(got) != 1 || got[0].Block != Leaves {
+		t.Errorf("pending blocks for the player's chunk = %v, want the one leaves block", got)
+	}
+
+	if got := cm.takePending(ChunkCoord{X: maxPendingChunks + 9}); len(got) != 0 {
+		t.Errorf("the farthest chunk still has %d pending blocks, want them dropped", len(got))
+	}
+
+	if got := cm.DroppedFeatureBlocks(); got != 10 {
+		t.Errorf("DroppedFeatureBlocks() = %d, want 10", got)
+	}
+}
+
+// brokenStore fills part of every chunk it loads with stone and torchlight and then fails, as a store
+// does when a record is cut short.
+type brokenStore struct{}
+
+func (brokenStore) LoadChunk(_ ChunkCoord, bs BlockStorage, lm LightMapContainer) (bool, error) {
+	for x := 0; x < ChunkSize; x++ {
+		bs.Set(x, ChunkSize-1, 0, Stone)
+	}
+
+	for i := range lm[:ChunkSizeSquared] {
+		lm[i] = 0x0F
+	}
+
+	return false, errors.New("unexpected EOF")
+}
+
+func (brokenStore) SaveChunk(ChunkCoord, BlockStorage, LightMapContainer) error {
+	return nil
+}
+
+func TestChunkManagerLoadFailureGenerates(t *testing.T) {
+	cm := NewChunkManager(&countingBackend{}, entity.NewPlayer(), WithGenerator(flatGenerator{}), WithStore(brokenStore{}), WithMeshWorkers(1))
+	defer cm.Close()
+
+	// Setup loads the chunk at the origin before returning; the flat generator leaves it empty.
+	tests := []struct {
+		name       string
+		x, y, z    int
+		block      BlockType
+		torchlight uint8
+		sunlight   uint8
+	}{
+		{"top row", 5, ChunkSize - 1, 0, Empty, 0, MaxLightLevel},
+		{"bottom", 0, 0, 0, Empty, 0, MaxLightLevel},
+	}
+
+	for _, tt := range tests {
+		bt, ok := cm.BlockAt(tt.x, tt.y, tt.z)
+		if !ok {
+			t.Fatalf("%s: chunk at the origin is not loaded", tt.name)
+		}
+
+		if bt != tt.block {
+			t.Errorf("%s: block is %d, want %d", tt.name, bt, tt.block)
+		}
+
+		if tl, sl, _ := cm.LightAt(tt.x, tt.y, tt.z); tl != tt.torchlight || sl != tt.sunlight {
+			t.Errorf("%s: light is %d torch and %d sun, want %d and %d", tt.name, tl, sl, tt.torchlight, tt.sunlight)
+		}
+	}
+}
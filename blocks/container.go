@@ -1,6 +1,6 @@
 package blocks
 
-import "fmt"
+import "sync"
 
 func idx(x, y, z int) int {
 	//return x + y*ChunkSize + z*ChunkSizeSquared
@@ -39,18 +39,87 @@ func (c LightMapContainer) SetTorchlight(x, y, z int, val uint8) {
 	c[i] = (c[i] & 0xF0) | val
 }
 
-// TODO: these should be locked via mutex or updated via channels
-type ChunkContainer map[string]*Chunk
+// ChunkCoord is the position of a chunk in the chunk grid.
+type ChunkCoord struct {
+	X, Y, Z int32
+}
+
+// Offset returns the coordinate of the chunk dx, dy, dz chunks away.
+func (c ChunkCoord) Offset(dx, dy, dz int32) ChunkCoord {
+	return ChunkCoord{c.X + dx, c.Y + dy, c.Z + dz}
+}
+
+// ChunkContainer stores the loaded chunks by their grid coordinate. It is safe for concurrent use.
+type ChunkContainer struct {
+	mu     sync.RWMutex
+	chunks map[ChunkCoord]*Chunk
+}
 
-func (c ChunkContainer) Lookup(x, y, z int) (*Chunk, bool) {
-	ch, ok := c[fmt.Sprintf("%d%d%d", x, y, z)]
+func NewChunkContainer() *ChunkContainer {
+	return &ChunkContainer{
+		chunks: make(map[ChunkCoord]*Chunk),
+	}
+}
+
+func (c *ChunkContainer) Lookup(coord ChunkCoord) (*Chunk, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	ch, ok := c.chunks[coord]
 	return ch, ok
 }
 
-func (c ChunkContainer) Set(x, y, z int, ch *Chunk) {
-	c[fmt.Sprintf("%d%d%d", x, y, z)] = ch
+func (c *ChunkContainer) Set(coord ChunkCoord, ch *Chunk) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.chunks[coord] = ch
+}
+
+func (c *ChunkContainer) Unset(coord ChunkCoord) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.chunks, coord)
+}
+
+func (c *ChunkContainer) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return len(c.chunks)
+}
+
+// Snapshot returns the chunks stored at the time of the call. The container may be modified while
+// the result is being iterated.
+func (c *ChunkContainer) Snapshot() []*Chunk {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	chunks := make([]*Chunk, 0, len(c.chunks))
+	for _, ch := range c.chunks {
+		chunks = append(chunks, ch)
+	}
+
+	return chunks
 }
 
-func (c ChunkContainer) Unset(x, y, z int) {
-	delete(c, fmt.Sprintf("%d%d%d", x, y, z))
+// Neighbourhood returns the loaded chunks within radius chunks of coord along every axis, including
+// the chunk at coord itself.
+func (c *ChunkContainer) Neighbourhood(coord ChunkCoord, radius int32) []*Chunk {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var chunks []*Chunk
+	for x := -radius; x <= radius; x++ {
+		for y := -radius; y <= radius; y++ {
+			for z := -radius; z <= radius; z++ {
+				if ch, ok := c.chunks[coord.Offset(x, y, z)]; ok {
+					chunks = append(chunks, ch)
+				}
+			}
+		}
+	}
+
+	return chunks
 }
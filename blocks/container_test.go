@@ -0,0 +1,181 @@
+package blocks
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestChunkContainerDistinguishesCoords(t *testing.T) {
+	c := NewChunkContainer()
+
+	// The old string keys formatted both of these as "1234".
+	a, b := &Chunk{}, &Chunk{}
+	c.Set(ChunkCoord{1, 23, 4}, a)
+	c.Set(ChunkCoord{12, 3, 4}, b)
+
+	if got, ok := c.Lookup(ChunkCoord{1, 23, 4}); !ok || got != a {
+		t.Errorf("Lookup(1, 23, 4) = %p, %v; want %p, true", got, ok, a)
+	}
+
+	if got, ok := c.Lookup(ChunkCoord{12, 3, 4}); !ok || got != b {
+		t.Errorf("Lookup(12, 3, 4) = %p, %v; want %p, true", got, ok, b)
+	}
+
+	if n := c.Len(); n != 2 {
+		t.Errorf("Len() = %d; want 2", n)
+	}
+
+	c.Unset(ChunkCoord{1, 23, 4})
+
+	if _, ok := c.Lookup(ChunkCoord{1, 23, 4}); ok {
+		t.Error("Lookup(1, 23, 4) found a chunk after Unset")
+	}
+
+	if _, ok := c.Lookup(ChunkCoord{12, 3, 4}); !ok {
+		t.Error("Unset(1, 23, 4) removed the chunk at 12, 3, 4")
+	}
+}
+
+func TestChunkContainerNeighbourhood(t *testing.T) {
+	c := NewChunkContainer()
+
+	for x := int32(-2); x <= 2; x++ {
+		for y := int32(-2); y <= 2; y++ {
+			for z := int32(-2); z <= 2; z++ {
+				c.Set(ChunkCoord{x, y, z}, &Chunk{})
+			}
+		}
+	}
+
+	if n := len(c.Neighbourhood(ChunkCoord{}, 1)); n != 27 {
+		t.Errorf("Neighbourhood(origin, 1) returned %d chunks; want 27", n)
+	}
+
+	if n := len(c.Neighbourhood(ChunkCoord{2, 2, 2}, 1)); n != 8 {
+		t.Errorf("Neighbourhood(corner, 1) returned %d chunks; want 8", n)
+	}
+
+	if n := len(c.Snapshot()); n != 125 {
+		t.Errorf("Snapshot() returned %d chunks; want 125", n)
+	}
+}
+
+// TestChunkContainerConcurrent is meant to be run with -race.
+func TestChunkContainerConcurrent(t *testing.T) {
+	c := NewChunkContainer()
+
+	var wg sync.WaitGroup
+	for w := int32(0); w < 8; w++ {
+		wg.Add(1)
+		go func(w int32) {
+			defer wg.Done()
+
+			for i := int32(0); i < 500; i++ {
+				coord := ChunkCoord{w, i % 10, i % 7}
+
+				c.Set(coord, &Chunk{})
+				c.Lookup(coord.Offset(1, 0, 0))
+				c.Neighbourhood(coord, 1)
+
+				for _, ch := range c.Snapshot() {
+					_ = ch
+				}
+
+				if i%3 == 0 {
+					c.Unset(coord)
+				}
+			}
+		}(w)
+	}
+
+	wg.Wait()
+
+	if c.Len() == 0 {
+		t.Error("no chunks left after concurrent Set and Unset")
+	}
+}
+
+// sprintfContainer is the map keyed by formatted coordinates that ChunkContainer replaced.
+type sprintfContainer map[string]*Chunk
+
+func (c sprintfContainer) Lookup(x, y, z int) (*Chunk, bool) {
+	ch, ok := c[fmt.Sprintf("%d%d%d", x, y, z)]
+	return ch, ok
+}
+
+func (c sprintfContainer) Set(x, y, z int, ch *Chunk) {
+	c[fmt.Sprintf("%d%d%d", x, y, z)] = ch
+}
+
+const benchRadius = 8
+
+func BenchmarkChunkContainerLookup(b *testing.B) {
+	c := NewChunkContainer()
+	for x := int32(-benchRadius); x < benchRadius; x++ {
+		for z := int32(-benchRadius); z < benchRadius; z++ {
+			c.Set(ChunkCoord{x, 0, z}, &Chunk{})
+		}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		c.Lookup(ChunkCoord{int32(i%(2*benchRadius)) - benchRadius, 0, int32(i/7%(2*benchRadius)) - benchRadius})
+	}
+}
+
+func BenchmarkSprintfContainerLookup(b *testing.B) {
+	c := sprintfContainer{}
+	for x := -benchRadius; x < benchRadius; x++ {
+		for z := -benchRadius; z < benchRadius; z++ {
+			c.Set(x, 0, z, &Chunk{})
+		}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		c.Lookup(i%(2*benchRadius)-benchRadius, 0, i/7%(2*benchRadius)-benchRadius)
+	}
+}
+
+func BenchmarkChunkContainerSet(b *testing.B) {
+	c := NewChunkContainer()
+	ch := &Chunk{}
+
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		c.Set(ChunkCoord{int32(i % 64), 0, int32(i / 64 % 64)}, ch)
+	}
+}
+
+func BenchmarkSprintfContainerSet(b *testing.B) {
+	c := sprintfContainer{}
+	ch := &Chunk{}
+
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		c.Set(i%64, 0, i/64%64, ch)
+	}
+}
+
+func BenchmarkChunkContainerLookupParallel(b *testing.B) {
+	c := NewChunkContainer()
+	for x := int32(-benchRadius); x < benchRadius; x++ {
+		c.Set(ChunkCoord{x, 0, 0}, &Chunk{})
+	}
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		i := int32(0)
+		for pb.Next() {
+			c.Lookup(ChunkCoord{i%(2*benchRadius) - benchRadius, 0, 0})
+			i++
+		}
+	})
+}
@@ -0,0 +1,190 @@
+package blocks
+
+import (
+	"math/rand"
+)
+
+// Feature is a multi-block decoration placed on generated terrain, such as a tree.
+type Feature interface {
+	// Place writes the feature's blocks with set, in world block coordinates, where x, y, z is the
+	// empty block resting on the ground. Only empty blocks are filled, so features never cut into the
+	// terrain or each other. rng is seeded from the position so that placement is deterministic.
+	Place(set func(x, y, z int, bt BlockType), x, y, z int, rng *rand.Rand)
+}
+
+// Tree is a trunk with a round canopy of leaves at the top.
+type Tree struct {
+	Trunk, Leaves        BlockType
+	MinHeight, MaxHeight int
+	LeafRadius           int
+}
+
+func (t Tree) Place(set func(x, y, z int, bt BlockType), x, y, z int, rng *rand.Rand) {
+	height := t.MinHeight + rng.Intn(t.MaxHeight-t.MinHeight+1)
+
+	for dy := 0; dy < height; dy++ {
+		set(x, y+dy, z, t.Trunk)
+	}
+
+	top := y + height - 1
+	r := t.LeafRadius
+
+	for dx := -r; dx <= r; dx++ {
+		for dy := -r; dy <= r; dy++ {
+			for dz := -r; dz <= r; dz++ {
+				if dx*dx+dy*dy+dz*dz <= r*r+1 {
+					set(x+dx, top+dy, z+dz, t.Leaves)
+				}
+			}
+		}
+	}
+}
+
+// Boulder is a rough ball of Block half sunk into the ground.
+type Boulder struct {
+	Block                BlockType
+	MinRadius, MaxRadius int
+}
+
+func (b Boulder) Place(set func(x, y, z int, bt BlockType), x, y, z int, rng *rand.Rand) {
+	r := b.MinRadius + rng.Intn(b.MaxRadius-b.MinRadius+1)
+
+	for dx := -r; dx <= r; dx++ {
+		for dy := -r; dy <= r; dy++ {
+			for dz := -r; dz <= r; dz++ {
+				// Roughen the surface by dropping some of the outermost blocks.
+				d := dx*dx + dy*dy + dz*dz
+				if d < r*r || (d <= r*r+r && rng.Intn(2) == 0) {
+					set(x+dx, y+dy, z+dz, b.Block)
+				}
+			}
+		}
+	}
+}
+
+// Template is a fixed structure, given as blocks keyed by their offset from the block resting on the
+// ground.
+type Template struct {
+	Blocks map[[3]int]BlockType
+}
+
+func (t Template) Place(set func(x, y, z int, bt BlockType), x, y, z int, rng *rand.Rand) {
+	for o, bt := range t.Blocks {
+		set(x+o[0], y+o[1], z+o[2], bt)
+	}
+}
+
+// Decoration says where a feature may be placed. Each chunk picks Attempts random columns, and the
+// feature is placed with probability Chance on each whose top block is one of Ground.
+type Decoration struct {
+	Feature  Feature
+	Ground   []BlockType
+	Attempts int
+	Chance   float64
+}
+
+// DefaultDecorations returns trees and boulders for the terrain of the DefaultBiomes.
+func DefaultDecorations() []Decoration {
+	return []Decoration{
+		{
+			Feature:  Tree{Trunk: Log, Leaves: Leaves, MinHeight: 4, MaxHeight: 7, LeafRadius: 2},
+			Ground:   []BlockType{Grass},
+			Attempts: 16,
+			Chance:   0.5,
+		},
+		{
+			Feature:  Boulder{Block: Stone, MinRadius: 1, MaxRadius: 2},
+			Ground:   []BlockType{Grass, Sand, Snow},
+			Attempts: 2,
+			Chance:   0.25,
+		},
+	}
+}
+
+// PlacedBlock is a block written by a feature, in world block coordinates.
+type PlacedBlock struct {
+	X, Y, Z int
+	Block   BlockType
+}
+
+// Decorator places features on newly generated chunks.
+type Decorator struct {
+	Seed        int64
+	Decorations []Decoration
+}
+
+func NewDecorator(seed int64, decorations ...Decoration) *Decorator {
+	return &Decorator{
+		Seed:        seed,
+		Decorations: decorations,
+	}
+}
+
+// Decorate places features on the freshly generated blocks of the chunk at coord. Blocks that fall
+// inside the chunk are written straight into it, and those that spill into other chunks are returned.
+// The same chunk is always decorated in the same way.
+func (d *Decorator) Decorate(coord ChunkCoord, blocks BlockStorage) []PlacedBlock {
+	var outside []PlacedBlock
+
+	ox, oy, oz := int(coord.X)*ChunkSize, int(coord.Y)*ChunkSize, int(coord.Z)*ChunkSize
+
+	set := func(x, y, z int, bt BlockType) {
+		lx, ly, lz := x-ox, y-oy, z-oz
+
+		if lx < 0 || lx >= ChunkSize || ly < 0 || ly >= ChunkSize || lz < 0 || lz >= ChunkSize {
+			outside = append(outside, PlacedBlock{x, y, z, bt})
+			return
+		}
+
+		if blocks.Lookup(lx, ly, lz) == Empty {
+			blocks.Set(lx, ly, lz, bt)
+		}
+	}
+
+	rng := rand.New(rand.NewSource(chunkSeed(d.Seed, coord)))
+
+	for _, dec := range d.Decorations {
+		for i := 0; i < dec.Attempts; i++ {
+			x, z := rng.Intn(ChunkSize), rng.Intn(ChunkSize)
+			place := rng.Float64() < dec.Chance
+
+			// The top layer is skipped as the chunk above may cover it.
+			y := ChunkSize - 2
+			for y >= 0 && blocks.Lookup(x, y, z) == Empty {
+				y--
+			}
+
+			if !place || y < 0 || blocks.Lookup(x, y+1, z) != Empty || !isGround(blocks.Lookup(x, y, z), dec.Ground) {
+				continue
+			}
+
+			dec.Feature.Place(set, ox+x, oy+y+1, oz+z, rng)
+		}
+	}
+
+	return outside
+}
+
+func isGround(bt BlockType, ground []BlockType) bool {
+	for _, g := range ground {
+		if bt == g {
+			return true
+		}
+	}
+
+	return false
+}
+
+// chunkSeed mixes the chunk's coordinate into seed with the SplitMix64 finaliser so that every chunk
+// gets an unrelated sequence of random numbers.
+func chunkSeed(seed int64, c ChunkCoord) int64 {
+	z := uint64(seed)
+	for _, v := range []int32{c.X, c.Y, c.Z} {
+		z += uint64(uint32(v)) + 0x9e3779b97f4a7c15
+		z = (z ^ z>>30) * 0xbf58476d1ce4e5b9
+		z = (z ^ z>>27) * 0x94d049bb133111eb
+		z ^= z >> 31
+	}
+
+	return int64(z)
+}
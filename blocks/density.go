@@ -0,0 +1,193 @@
+package blocks
+
+import (
+	"math"
+
+	"github.com/nickbryan/voxel/noise"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+// DensityParams tunes a DensityGenerator. Distances and amplitudes are in blocks and frequencies are
+// in cycles per block.
+type DensityParams struct {
+	Seed int64
+
+	// Biomes are chosen by sampling climate noise at ClimateFrequency. Each sets the height of the
+	// surface from a shared height noise, and heights are blended between biomes within BiomeBlend of
+	// each other in the climate. There must be at least one biome.
+	Biomes                       []Biome
+	ClimateFrequency, BiomeBlend float64
+
+	HeightFrequency float64
+	HeightOctaves   int
+
+	// Overhangs push the surface in and out by up to OverhangAmplitude using 3D noise.
+	OverhangAmplitude, OverhangFrequency float64
+	OverhangOctaves                      int
+
+	// Cheese caves are carved wherever the cave noise is above CheeseThreshold, at least CaveDepth
+	// below the surface.
+	CheeseFrequency, CheeseThreshold float64
+
+	// Worm caves are carved where two noise fields are both within WormWidth of zero, giving long
+	// tunnels that may break out at the surface.
+	WormFrequency, WormWidth float64
+
+	CaveDepth float64
+
+	// Open space below SeaLevel is filled with Water. Caves more than OverhangAmplitude below the
+	// surface are left dry.
+	SeaLevel float64
+	Water    BlockType
+
+	// Underground fills everything below the biome's surface and subsurface blocks.
+	Underground BlockType
+}
+
+// DefaultDensityParams returns the parameters for the DefaultBiomes over stone riddled with caves.
+func DefaultDensityParams(seed int64) DensityParams {
+	return DensityParams{
+		Seed:              seed,
+		Biomes:            DefaultBiomes(),
+		ClimateFrequency:  0.002,
+		BiomeBlend:        0.2,
+		HeightFrequency:   0.005,
+		HeightOctaves:     4,
+		OverhangAmplitude: 8,
+		OverhangFrequency: 0.03,
+		OverhangOctaves:   2,
+		CheeseFrequency:   0.025,
+		CheeseThreshold:   0.55,
+		WormFrequency:     0.015,
+		WormWidth:         0.06,
+		CaveDepth:         4,
+		SeaLevel:          0,
+		Water:             Water,
+		Underground:       Stone,
+	}
+}
+
+// DensityGenerator decides whether each block is solid from a density function: the distance below a
+// 2D height map, pushed around by 3D noise so that the terrain can fold over itself. Caves are then
+// carved out of the solid blocks. Solid blocks with open space above them become the biome's surface
+// block, followed by its subsurface blocks and then Underground blocks.
+type DensityGenerator struct {
+	DensityParams
+
+	climate                                *noise.Climate
+	height, overhang, cheese, wormA, wormB *noise.OctaveNoise
+
+	// maxDepth is the deepest any biome's surface and subsurface blocks reach.
+	maxDepth int
+}
+
+func NewDensityGenerator(p DensityParams) *DensityGenerator {
+	maxDepth := 1
+	for _, b := range p.Biomes {
+		if b.SubsurfaceDepth+1 > maxDepth {
+			maxDepth = b.SubsurfaceDepth + 1
+		}
+	}
+
+	return &DensityGenerator{
+		DensityParams: p,
+		maxDepth:      maxDepth,
+		climate:       noise.NewClimate(p.Seed, p.ClimateFrequency),
+		height:        newFractal(p.Seed, p.HeightOctaves, p.HeightFrequency),
+		overhang:      newFractal(p.Seed+1, p.OverhangOctaves, p.OverhangFrequency),
+		cheese:        newFractal(p.Seed+2, 2, p.CheeseFrequency),
+		wormA:         newFractal(p.Seed+3, 1, p.WormFrequency),
+		wormB:         newFractal(p.Seed+4, 1, p.WormFrequency),
+	}
+}
+
+// newFractal creates normalised noise where each octave doubles the frequency and halves the amplitude.
+func newFractal(seed int64, octaves int, frequency float64) *noise.OctaveNoise {
+	n := noise.NewOctave(seed, octaves)
+	n.Frequency = frequency
+	n.Lacunarity = 2
+	n.Persistence = 0.5
+	n.Normalized = true
+
+	return n
+}
+
+func (g *DensityGenerator) Generate(blocks BlockStorage, pos mgl64.Vec3) {
+	// Blocks above the chunk are sampled too so that the surface and subsurface blocks of a column
+	// continue correctly from the chunk above.
+	solid := make([]bool, ChunkSize+g.maxDepth)
+
+	for x := 0; x < ChunkSize; x++ {
+		xPos := pos.X() + float64(x)
+
+		for z := 0; z < ChunkSize; z++ {
+			zPos := pos.Z() + float64(z)
+
+			height, biome := g.Column(xPos, zPos)
+
+			for y := range solid {
+				solid[y] = g.Solid(xPos, pos.Y()+float64(y), zPos, height)
+			}
+
+			// A column that is solid all the way to the top of the samples is already deeper than any
+			// subsurface blocks.
+			depth := g.maxDepth
+			for y := len(solid) - 1; y >= 0; y-- {
+				if solid[y] {
+					depth++
+				} else {
+					depth = 0
+				}
+
+				if y >= ChunkSize {
+					continue
+				}
+
+				yPos := pos.Y() + float64(y)
+
+				switch {
+				case depth == 0 && yPos < g.SeaLevel && yPos > height-g.OverhangAmplitude:
+					blocks.Set(x, y, z, g.Water)
+				case depth == 0:
+					blocks.Set(x, y, z, Empty)
+				case depth == 1:
+					blocks.Set(x, y, z, biome.Surface)
+				case depth <= 1+biome.SubsurfaceDepth:
+					blocks.Set(x, y, z, biome.Subsurface)
+				default:
+					blocks.Set(x, y, z, g.Underground)
+				}
+			}
+		}
+	}
+}
+
+// Column returns the height of the surface at the world position x, z before overhangs and caves,
+// along with the biome that the column belongs to.
+func (g *DensityGenerator) Column(x, z float64) (float64, *Biome) {
+	temperature, humidity := g.climate.Sample(x, z)
+
+	return blendBiomes(g.Biomes, temperature, humidity, g.height.Eval2(x, z), g.BiomeBlend)
+}
+
+// Solid reports whether the block at the given world position is solid, where height is the result of
+// Column for its column.
+func (g *DensityGenerator) Solid(x, y, z, height float64) bool {
+	density := height - y
+
+	// Skip the 3D noise away from the surface, where it cannot change the outcome.
+	if math.Abs(density) < g.OverhangAmplitude {
+		density += g.OverhangAmplitude * g.overhang.Eval3(x, y, z)
+	}
+
+	if density <= 0 {
+		return false
+	}
+
+	if density > g.CaveDepth && g.cheese.Eval3(x, y, z) > g.CheeseThreshold {
+		return false
+	}
+
+	return math.Abs(g.wormA.Eval3(x, y, z)) > g.WormWidth || math.Abs(g.wormB.Eval3(x, y, z)) > g.WormWidth
+}
@@ -0,0 +1,113 @@
+package blocks
+
+// MaxFluidLevel is the level of a fluid source block. Fluid spreading sideways loses a level with each
+// block, while fluid falling from above is one level below a source.
+const MaxFluidLevel uint8 = 7
+
+// FluidTickInterval is how often, in seconds, TickFluids should be called.
+const FluidTickInterval = 0.25
+
+// maxFluidUpdates caps the number of blocks checked by one call to TickFluids so that a large flood is
+// spread over several ticks.
+const maxFluidUpdates = 4096
+
+// activateFluids queues the block at the given world block coordinates and its neighbours to be
+// checked by the next fluid tick.
+func (cm *ChunkManager) activateFluids(x, y, z int) {
+	cm.fluidMu.Lock()
+	defer cm.fluidMu.Unlock()
+
+	cm.activeFluids[[3]int{x, y, z}] = true
+
+	for _, o := range faceOffsets {
+		cm.activeFluids[[3]int{x + o[0], y + o[1], z + o[2]}] = true
+	}
+}
+
+// TickFluids advances the fluid simulation by one step. Each queued block works out its new fluid level
+// from its neighbours, and every block that changes is set with SetBlock, which relights and remeshes
+// it and queues it and its neighbours for the next tick. Source blocks never change, so fluid spreads
+// out from them until it runs out of levels and drains away again when they are removed.
+func (cm *ChunkManager) TickFluids() {
+	cm.fluidMu.Lock()
+
+	var active [][3]int
+	for p := range cm.activeFluids {
+		if len(active) == maxFluidUpdates {
+			break
+		}
+
+		active = append(active, p)
+		delete(cm.activeFluids, p)
+	}
+
+	cm.fluidMu.Unlock()
+
+	// Work out every change before making any so that the result does not depend on the order that
+	// the blocks are visited in.
+	type change struct {
+		pos [3]int
+		bt  BlockType
+	}
+
+	var changes []change
+	for _, p := range active {
+		if bt, ok := cm.nextFluid(p[0], p[1], p[2]); ok {
+			changes = append(changes, change{p, bt})
+		}
+	}
+
+	for _, c := range changes {
+		cm.SetBlock(c.pos[0], c.pos[1], c.pos[2], c.bt)
+	}
+}
+
+// nextFluid returns the block that the block at the given world coordinates should become, reporting
+// false if it should stay as it is. Only empty blocks and flowing fluid change: they fill from fluid
+// above, or from fluid beside them that is resting on something, one level lower than that fluid.
+func (cm *ChunkManager) nextFluid(x, y, z int) (BlockType, bool) {
+	bt, ok := cm.BlockAt(x, y, z)
+	if !ok {
+		return Empty, false
+	}
+
+	current := bt.Definition().FluidLevel
+	if (bt != Empty && current == 0) || current == MaxFluidLevel {
+		return Empty, false
+	}
+
+	level := uint8(0)
+
+	if above, ok := cm.BlockAt(x, y+1, z); ok && above.Definition().FluidLevel > 0 {
+		level = MaxFluidLevel - 1
+	}
+
+	for _, o := range faceOffsets {
+		if o[1] != 0 {
+			continue
+		}
+
+		nx, nz := x+o[0], z+o[2]
+
+		n, ok := cm.BlockAt(nx, y, nz)
+		if !ok {
+			continue
+		}
+
+		// Fluid only spreads sideways when resting on a block or a source; otherwise it keeps falling.
+		below, ok := cm.BlockAt(nx, y-1, nz)
+		if l := below.Definition().FluidLevel; !ok || below == Empty || (l > 0 && l < MaxFluidLevel) {
+			continue
+		}
+
+		if l := n.Definition().FluidLevel; l > 1 && l-1 > level {
+			level = l - 1
+		}
+	}
+
+	if level == current {
+		return Empty, false
+	}
+
+	return flowingWater[level], true
+}
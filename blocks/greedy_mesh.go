@@ -0,0 +1,192 @@
+package blocks
+
+import (
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+// GreedyMesher merges coplanar faces of the same block type and light level into as few quads as
+// possible. It produces the same visible surface as the CulledMesher with far fewer vertices.
+type GreedyMesher struct {
+	Meshes   ChunkMeshes
+	Blocks   BlockStorage
+	LightMap LightMapContainer
+	Offset   mgl64.Vec3
+
+	Neighbours
+}
+
+func NewGreedyMesher() Mesher {
+	return &GreedyMesher{}
+}
+
+func (gm *GreedyMesher) BuildMesh(meshes ChunkMeshes, blocks BlockStorage, lightMap LightMapContainer, offset mgl64.Vec3, n Neighbours) {
+	m := GreedyMesher{
+		Meshes:     meshes,
+		Blocks:     blocks,
+		LightMap:   lightMap,
+		Offset:     offset,
+		Neighbours: n,
+	}
+
+	m.Update()
+}
+
+type greedyFace struct {
+	bt         BlockType
+	torchlight uint8
+	sunlight   uint8
+	visible    bool
+}
+
+func (gm *GreedyMesher) Update() {
+	mask := make([]greedyFace, ChunkSizeSquared)
+
+	for d := 0; d < 3; d++ {
+		u := (d + 1) % 3
+		v := (d + 2) % 3
+
+		for _, dir := range []int{1, -1} {
+			for i := 0; i < ChunkSize; i++ {
+				gm.buildMask(mask, d, u, v, i, dir)
+				gm.mergeMask(mask, d, u, v, i, dir)
+			}
+		}
+	}
+}
+
+// buildMask fills the mask with the faces of slice i along axis d that point in direction dir.
+func (gm *GreedyMesher) buildMask(mask []greedyFace, d, u, v, i, dir int) {
+	var p, q [3]int
+
+	for b := 0; b < ChunkSize; b++ {
+		for a := 0; a < ChunkSize; a++ {
+			p[d], p[u], p[v] = i, a, b
+			q = p
+			q[d] += dir
+
+			f := greedyFace{}
+
+			if bt := gm.Blocks.Lookup(p[0], p[1], p[2]); !bt.Definition().Invisible && gm.faceVisible(bt, q, d) {
+				tl, sl := lightLevels(gm.LightMap, gm.Neighbours, q[0], q[1], q[2])
+
+				f = greedyFace{
+					bt:         bt,
+					torchlight: tl,
+					sunlight:   sl,
+					visible:    true,
+				}
+			}
+
+			mask[a+b*ChunkSize] = f
+		}
+	}
+}
+
+// mergeMask walks the mask emitting the largest rectangles of matching faces it can find.
+func (gm *GreedyMesher) mergeMask(mask []greedyFace, d, u, v, i, dir int) {
+	for b := 0; b < ChunkSize; b++ {
+		for a := 0; a < ChunkSize; {
+			f := mask[a+b*ChunkSize]
+			if !f.visible {
+				a++
+				continue
+			}
+
+			w := 1
+			for a+w < ChunkSize && mask[a+w+b*ChunkSize] == f {
+				w++
+			}
+
+			h := 1
+		grow:
+			for b+h < ChunkSize {
+				for k := 0; k < w; k++ {
+					if mask[a+k+(b+h)*ChunkSize] != f {
+						break grow
+					}
+				}
+				h++
+			}
+
+			gm.addQuad(d, u, v, i, dir, a, b, w, h, f)
+
+			for l := 0; l < h; l++ {
+				for k := 0; k < w; k++ {
+					mask[a+k+(b+l)*ChunkSize] = greedyFace{}
+				}
+			}
+
+			a += w
+		}
+	}
+}
+
+func (gm *GreedyMesher) addQuad(d, u, v, i, dir, a, b, w, h int, f greedyFace) {
+	var base, du, dv mgl64.Vec3
+
+	base[d] = float64(i) + float64(dir)*BlockRenderSize
+	base[u] = float64(a) - BlockRenderSize
+	base[v] = float64(b) - BlockRenderSize
+	base = base.Add(gm.Offset)
+
+	du[u] = float64(w) * BlockSize
+	dv[v] = float64(h) * BlockSize
+
+	face := FaceLeft + Face(d*2)
+	if dir > 0 {
+		face++
+	}
+
+	mesh := gm.Meshes.For(f.bt)
+	def := f.bt.Definition()
+
+	mesh.SetColor(def.Colors[face])
+	mesh.SetAlpha(1 - def.Translucency)
+	mesh.SetLight(f.torchlight, f.sunlight)
+
+	// Keep the winding counter clockwise when looking at the face from outside of the block.
+	if dir < 0 {
+		du, dv = dv, du
+	}
+
+	v1 := mesh.AddVertex(base)
+	v2 := mesh.AddVertex(base.Add(du))
+	v3 := mesh.AddVertex(base.Add(du).Add(dv))
+	v4 := mesh.AddVertex(base.Add(dv))
+
+	mesh.AddTriangle(v1, v2, v3)
+	mesh.AddTriangle(v1, v3, v4)
+}
+
+// faceVisible reports whether the block at q, which neighbours a face of bt along axis d, leaves that
+// face exposed. Faces on the chunk border are only visible when the neighbouring chunk is loaded.
+func (gm *GreedyMesher) faceVisible(bt BlockType, q [3]int, d int) bool {
+	if q[d] >= 0 && q[d] < ChunkSize {
+		return !faceHidden(bt, gm.Blocks.Lookup(q[0], q[1], q[2]))
+	}
+
+	var neighbour *Chunk
+
+	switch {
+	case d == 0 && q[d] < 0:
+		neighbour = gm.XMinus
+	case d == 0:
+		neighbour = gm.XPlus
+	case d == 1 && q[d] < 0:
+		neighbour = gm.YMinus
+	case d == 1:
+		neighbour = gm.YPlus
+	case d == 2 && q[d] < 0:
+		neighbour = gm.ZMinus
+	default:
+		neighbour = gm.ZPlus
+	}
+
+	if neighbour == nil {
+		return false
+	}
+
+	q[d] = (q[d] + ChunkSize) % ChunkSize
+
+	return !faceHidden(bt, neighbour.blocks.Lookup(q[0], q[1], q[2]))
+}
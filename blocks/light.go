@@ -0,0 +1,238 @@
+package blocks
+
+import (
+	"container/list"
+)
+
+// MaxLightLevel is the brightest value a light map nibble can hold.
+const MaxLightLevel uint8 = 15
+
+// faceOffsets are the directions light spreads in from a block.
+var faceOffsets = [6][3]int{
+	{-1, 0, 0}, {1, 0, 0},
+	{0, -1, 0}, {0, 1, 0},
+	{0, 0, -1}, {0, 0, 1},
+}
+
+// LightRemovalNode is a node in the light removal queue. Level holds the light value the node had
+// before it was cleared.
+type LightRemovalNode struct {
+	LightNode
+	Level uint8
+}
+
+// neighbour returns the node offset from n by dx, dy, dz, which must be at most one block along each
+// axis. Nodes on the chunk border step into the neighbouring chunk; ok is false when that chunk is not
+// loaded.
+func (n LightNode) neighbour(dx, dy, dz int) (LightNode, bool) {
+	nb := LightNode{Chunk: n.Chunk, X: n.X + dx, Y: n.Y + dy, Z: n.Z + dz}
+
+	if nb.X >= 0 && nb.X < ChunkSize && nb.Y >= 0 && nb.Y < ChunkSize && nb.Z >= 0 && nb.Z < ChunkSize {
+		return nb, true
+	}
+
+	neighbours := n.Chunk.neighbours()
+
+	switch {
+	case nb.X < 0:
+		nb.Chunk, nb.X = neighbours.XMinus, ChunkSize-1
+	case nb.X >= ChunkSize:
+		nb.Chunk, nb.X = neighbours.XPlus, 0
+	case nb.Y < 0:
+		nb.Chunk, nb.Y = neighbours.YMinus, ChunkSize-1
+	case nb.Y >= ChunkSize:
+		nb.Chunk, nb.Y = neighbours.YPlus, 0
+	case nb.Z < 0:
+		nb.Chunk, nb.Z = neighbours.ZMinus, ChunkSize-1
+	default:
+		nb.Chunk, nb.Z = neighbours.ZPlus, 0
+	}
+
+	return nb, nb.Chunk != nil
+}
+
+// lightChannel selects which of the two values stored in a light map is being updated.
+type lightChannel int
+
+const (
+	torchlight lightChannel = iota
+	sunlight
+)
+
+func (n LightNode) light(ch lightChannel) uint8 {
+	if ch == sunlight {
+		return n.Chunk.lightMap.Sunlight(n.X, n.Y, n.Z)
+	}
+
+	return n.Chunk.lightMap.Torchlight(n.X, n.Y, n.Z)
+}
+
+func (n LightNode) setLight(ch lightChannel, val uint8) {
+	if ch == sunlight {
+		n.Chunk.lightMap.SetSunlight(n.X, n.Y, n.Z, val)
+		return
+	}
+
+	n.Chunk.lightMap.SetTorchlight(n.X, n.Y, n.Z, val)
+}
+
+func (n LightNode) block() BlockType {
+	return n.Chunk.blocks.Lookup(n.X, n.Y, n.Z)
+}
+
+// spreadsUnattenuated reports whether light travelling from a node at lvl in direction o keeps its full
+// strength. Full sunlight shines straight down without fading so that open columns stay fully lit.
+func spreadsUnattenuated(ch lightChannel, lvl uint8, o [3]int) bool {
+	return ch == sunlight && lvl == MaxLightLevel && o[1] == -1
+}
+
+// placeTorchlight sets the torchlight at the given node and spreads it to the surrounding blocks,
+// crossing into neighbouring chunks where they are loaded. It returns every chunk whose light map
+// changed.
+func placeTorchlight(n LightNode, level uint8) []*Chunk {
+	changed := map[*Chunk]bool{n.Chunk: true}
+
+	n.setLight(torchlight, level)
+
+	queue := list.New()
+	queue.PushBack(n)
+	propagateLight(torchlight, queue, changed)
+
+	return chunkList(changed)
+}
+
+// removeTorchlight clears the torchlight spread from the light source at the given node. It returns
+// every chunk whose light map changed.
+func removeTorchlight(n LightNode) []*Chunk {
+	changed := make(map[*Chunk]bool)
+
+	removeLight(torchlight, changed, n)
+
+	return chunkList(changed)
+}
+
+// removeLight clears the light held by the given nodes along with all of the light that was spread
+// from them. Light is removed outwards until a brighter area is found, which is then spread back into
+// the cleared blocks.
+func removeLight(ch lightChannel, changed map[*Chunk]bool, nodes ...LightNode) {
+	removalQueue := list.New()
+
+	for _, n := range nodes {
+		changed[n.Chunk] = true
+		removalQueue.PushBack(LightRemovalNode{LightNode: n, Level: n.light(ch)})
+		n.setLight(ch, 0)
+	}
+
+	queue := list.New()
+
+	for removalQueue.Len() > 0 {
+		e := removalQueue.Front()
+		removalQueue.Remove(e)
+		node := e.Value.(LightRemovalNode)
+
+		for _, o := range faceOffsets {
+			nb, ok := node.neighbour(o[0], o[1], o[2])
+			if !ok {
+				continue
+			}
+
+			nbLvl := nb.light(ch)
+
+			if nbLvl != 0 && (nbLvl < node.Level || spreadsUnattenuated(ch, node.Level, o)) {
+				nb.setLight(ch, 0)
+				changed[nb.Chunk] = true
+				removalQueue.PushBack(LightRemovalNode{LightNode: nb, Level: nbLvl})
+			} else if nbLvl >= node.Level {
+				queue.PushBack(nb)
+			}
+		}
+	}
+
+	propagateLight(ch, queue, changed)
+}
+
+// propagateLight runs a breadth first spread of the light held by each node in the queue, recording
+// the chunks it changes.
+func propagateLight(ch lightChannel, queue *list.List, changed map[*Chunk]bool) {
+	for queue.Len() > 0 {
+		e := queue.Front()
+		queue.Remove(e)
+		node := e.Value.(LightNode)
+
+		lvl := node.light(ch)
+		if lvl == 0 {
+			continue
+		}
+
+		for _, o := range faceOffsets {
+			nb, ok := node.neighbour(o[0], o[1], o[2])
+			if !ok || !nb.block().Definition().Transparent {
+				continue
+			}
+
+			next := lvl - 1
+			if spreadsUnattenuated(ch, lvl, o) {
+				next = lvl
+			}
+
+			if nb.light(ch) < next {
+				nb.setLight(ch, next)
+				changed[nb.Chunk] = true
+				queue.PushBack(nb)
+			}
+		}
+	}
+}
+
+func chunkList(set map[*Chunk]bool) []*Chunk {
+	chunks := make([]*Chunk, 0, len(set))
+	for ch := range set {
+		chunks = append(chunks, ch)
+	}
+
+	return chunks
+}
+
+// PlaceTorch places a light source at the given world block coordinates and queues every chunk the
+// light reaches for a mesh rebuild. It reports whether the chunk containing the block is loaded.
+func (cm *ChunkManager) PlaceTorch(x, y, z int) bool {
+	coord, lx, ly, lz := worldToLocal(x, y, z)
+
+	ch, ok := cm.chunks.Lookup(coord)
+	if !ok {
+		return false
+	}
+
+	cm.worldMu.Lock()
+	changedChunks := ch.PlaceTorch(lx, ly, lz)
+	cm.worldMu.Unlock()
+
+	for _, changed := range changedChunks {
+		changed.markDirty()
+		cm.meshWorkers.Enqueue(changed)
+	}
+
+	return true
+}
+
+// RemoveTorch removes the light source at the given world block coordinates and queues every chunk
+// the light reached for a mesh rebuild. It reports whether the chunk containing the block is loaded.
+func (cm *ChunkManager) RemoveTorch(x, y, z int) bool {
+	coord, lx, ly, lz := worldToLocal(x, y, z)
+
+	ch, ok := cm.chunks.Lookup(coord)
+	if !ok {
+		return false
+	}
+
+	cm.worldMu.Lock()
+	changedChunks := ch.RemoveTorch(lx, ly, lz)
+	cm.worldMu.Unlock()
+
+	for _, changed := range changedChunks {
+		changed.markDirty()
+		cm.meshWorkers.Enqueue(changed)
+	}
+
+	return true
+}
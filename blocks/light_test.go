@@ -0,0 +1,270 @@
+package blocks
+
+import (
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+// emptyChunk returns an unlit chunk of air at the given grid position.
+func emptyChunk(x, y, z float64) *Chunk {
+	c := &Chunk{GridPos: mgl64.Vec3{x, y, z}}
+	c.allocate()
+
+	return c
+}
+
+// linkedPair returns two empty chunks side by side along X, linked as neighbours.
+func linkedPair() (a, b *Chunk) {
+	a, b = emptyChunk(0, 0, 0), emptyChunk(1, 0, 0)
+	a.XPlus, b.XMinus = b, a
+
+	return a, b
+}
+
+// torchlightAlongX returns the torchlight at world x, 5, 5 across the pair from linkedPair.
+func torchlightAlongX(a, b *Chunk, x int) uint8 {
+	if x < ChunkSize {
+		return a.lightMap.Torchlight(x, 5, 5)
+	}
+
+	return b.lightMap.Torchlight(x-ChunkSize, 5, 5)
+}
+
+func TestTorchlightAcrossChunkBorder(t *testing.T) {
+	a, b := linkedPair()
+
+	changed := a.PlaceTorch(ChunkSize-1, 5, 5)
+	if len(changed) != 2 {
+		t.Errorf("PlaceTorch changed %d chunks, want 2", len(changed))
+	}
+
+	want := map[int]uint8{
+		ChunkSize - 16: 0,
+		ChunkSize - 2:  14,
+		ChunkSize - 1:  15,
+		ChunkSize:      14,
+		ChunkSize + 1:  13,
+		ChunkSize + 13: 1,
+		ChunkSize + 14: 0,
+	}
+
+	for x, lvl := range want {
+		if got := torchlightAlongX(a, b, x); got != lvl {
+			t.Errorf("torchlight at x=%d is %d, want %d", x, got, lvl)
+		}
+	}
+
+	if got := b.lightMap.Torchlight(1, 6, 5); got != 12 {
+		t.Errorf("torchlight diagonally across the border is %d, want 12", got)
+	}
+
+	a.RemoveTorch(ChunkSize-1, 5, 5)
+
+	for _, c := range []*Chunk{a, b} {
+		for i, l := range c.lightMap {
+			if l != 0 {
+				t.Fatalf("chunk %v still has light %#x at index %d after RemoveTorch", c.Coord(), l, i)
+			}
+		}
+	}
+}
+
+func TestTorchlightStopsAtUnloadedNeighbour(t *testing.T) {
+	a := emptyChunk(0, 0, 0)
+
+	changed := a.PlaceTorch(ChunkSize-1, 5, 5)
+	if len(changed) != 1 || changed[0] != a {
+		t.Errorf("PlaceTorch changed %v, want only the torch's chunk", changed)
+	}
+
+	if got := a.lightMap.Torchlight(ChunkSize-2, 5, 5); got != 14 {
+		t.Errorf("torchlight beside the torch is %d, want 14", got)
+	}
+}
+
+func TestTorchlightBlockedBySolid(t *testing.T) {
+	a, b := linkedPair()
+
+	// Wall off the torch in the first chunk on every side but -X.
+	for _, o := range faceOffsets[1:] {
+		x, y, z := ChunkSize-1+o[0], 5+o[1], 5+o[2]
+		if x == ChunkSize {
+			b.blocks.Set(0, y, z, Stone)
+			continue
+		}
+		a.blocks.Set(x, y, z, Stone)
+	}
+
+	a.PlaceTorch(ChunkSize-1, 5, 5)
+
+	if got := b.lightMap.Torchlight(0, 5, 5); got != 0 {
+		t.Errorf("torchlight inside the wall is %d, want 0", got)
+	}
+
+	// Light has to go around the wall: back one block, two out to clear it, across three and two back
+	// in again.
+	if got := b.lightMap.Torchlight(1, 5, 5); got != 7 {
+		t.Errorf("torchlight behind the wall is %d, want 7", got)
+	}
+}
+
+func TestSeedSunlight(t *testing.T) {
+	c := emptyChunk(0, 0, 0)
+
+	// A stone roof at y=10 with a single hole at 5, 5.
+	for x := 0; x < ChunkSize; x++ {
+		for z := 0; z < ChunkSize; z++ {
+			if x != 5 || z != 5 {
+				c.blocks.Set(x, 10, z, Stone)
+			}
+		}
+	}
+
+	seedSunlight(c, nil)
+
+	tests := []struct {
+		name    string
+		x, y, z int
+		want    uint8
+	}{
+		{"open sky", 20, ChunkSize - 1, 20, MaxLightLevel},
+		{"on the roof", 20, 11, 20, MaxLightLevel},
+		{"inside the roof", 20, 10, 20, 0},
+		{"through the hole", 5, 10, 5, MaxLightLevel},
+		{"bottom of the shaft", 5, 0, 5, MaxLightLevel},
+		{"beside the shaft", 6, 0, 5, 14},
+		{"two blocks from the shaft", 6, 0, 6, 13},
+		{"far under the roof", 25, 5, 25, 0},
+	}
+
+	for _, tt := range tests {
+		if got := c.lightMap.Sunlight(tt.x, tt.y, tt.z); got != tt.want {
+			t.Errorf("%s: sunlight at %d, %d, %d is %d, want %d", tt.name, tt.x, tt.y, tt.z, got, tt.want)
+		}
+	}
+}
+
+func TestSeedSunlightUnderDarkChunk(t *testing.T) {
+	above := emptyChunk(0, 1, 0)
+	c := emptyChunk(0, 0, 0)
+
+	// Only one column of the chunk above is lit at its base.
+	above.lightMap.SetSunlight(3, 0, 4, MaxLightLevel)
+
+	seedSunlight(c, above)
+
+	if got := c.lightMap.Sunlight(3, 0, 4); got != MaxLightLevel {
+		t.Errorf("sunlight under the lit column is %d, want %d", got, MaxLightLevel)
+	}
+
+	if got := c.lightMap.Sunlight(3, 0, 5); got != 14 {
+		t.Errorf("sunlight beside the lit column is %d, want 14", got)
+	}
+
+	if got := c.lightMap.Sunlight(20, ChunkSize-1, 20); got != 0 {
+		t.Errorf("sunlight under a dark column is %d, want 0", got)
+	}
+}
+
+// setBlock changes the block at the given position in the chunk and relights around it, as SetBlock
+// does for a ChunkManager.
+func setBlock(c *Chunk, x, y, z int, bt BlockType) {
+	old := c.blocks.Lookup(x, y, z)
+	c.blocks.Set(x, y, z, bt)
+	relightBlock(LightNode{Chunk: c, X: x, Y: y, Z: z}, old)
+}
+
+func TestRelightBlockUnderSky(t *testing.T) {
+	a, b := linkedPair()
+	seedSunlight(a, nil)
+	seedSunlight(b, nil)
+
+	// A roof at y=20 spanning the border, from x=24 in the first chunk to x=7 in the second, along the
+	// whole of Z. The open columns either side stay fully lit down to the bottom of the chunks.
+	roof := func(bt BlockType) {
+		for z := 0; z < ChunkSize; z++ {
+			for x := 24; x < ChunkSize+8; x++ {
+				if x < ChunkSize {
+					setBlock(a, x, 20, z, bt)
+				} else {
+					setBlock(b, x-ChunkSize, 20, z, bt)
+				}
+			}
+		}
+	}
+
+	roof(Stone)
+
+	tests := []struct {
+		name    string
+		c       *Chunk
+		x, y, z int
+		want    uint8
+	}{
+		{"above the roof", a, 31, 21, 5, MaxLightLevel},
+		{"inside the roof", b, 0, 20, 5, 0},
+		{"open column beside the roof", a, 23, 0, 5, MaxLightLevel},
+		{"open column across the border", b, 8, 0, 5, MaxLightLevel},
+		{"just under the edge", a, 24, 19, 5, 14},
+		{"under the roof", a, 28, 19, 5, 10},
+		{"under the roof at the border", a, 31, 10, 5, 7},
+		{"under the roof across the border", b, 0, 0, 5, 7},
+		{"under the far side", b, 3, 19, 5, 10},
+	}
+
+	for _, tt := range tests {
+		if got := tt.c.lightMap.Sunlight(tt.x, tt.y, tt.z); got != tt.want {
+			t.Errorf("%s: sunlight at %d, %d, %d is %d, want %d", tt.name, tt.x, tt.y, tt.z, got, tt.want)
+		}
+	}
+
+	// Taking the roof away again lets the sun back in everywhere.
+	roof(Empty)
+
+	for _, c := range []*Chunk{a, b} {
+		for i, l := range c.lightMap {
+			if l>>4 != MaxLightLevel {
+				t.Fatalf("chunk %v has sunlight %d at index %d after the roof was removed", c.Coord(), l>>4, i)
+			}
+		}
+	}
+}
+
+func TestRelightBorderUnderLoadedChunk(t *testing.T) {
+	lo, hi := emptyChunk(0, 0, 0), emptyChunk(0, 1, 0)
+
+	// A roof at y=5 of the upper chunk over the half of it with x < 16.
+	for x := 0; x < 16; x++ {
+		for z := 0; z < ChunkSize; z++ {
+			hi.blocks.Set(x, 5, z, Stone)
+		}
+	}
+
+	// The lower chunk loaded first, so it was seeded as if under open sky.
+	seedSunlight(lo, nil)
+	seedSunlight(hi, nil)
+
+	lo.YPlus, hi.YMinus = hi, lo
+	relightBorder(lo, hi)
+
+	tests := []struct {
+		name    string
+		c       *Chunk
+		x, y, z int
+		want    uint8
+	}{
+		{"open sky", lo, 20, ChunkSize - 1, 5, MaxLightLevel},
+		{"bottom of an open column", lo, 16, 0, 5, MaxLightLevel},
+		{"beside the open columns", lo, 15, ChunkSize - 1, 5, 14},
+		{"under the roof", lo, 10, 0, 5, 9},
+		{"far under the roof", lo, 1, ChunkSize - 1, 5, 0},
+		{"under the roof in the upper chunk", hi, 10, 0, 5, 9},
+	}
+
+	for _, tt := range tests {
+		if got := tt.c.lightMap.Sunlight(tt.x, tt.y, tt.z); got != tt.want {
+			t.Errorf("%s: sunlight at %d, %d, %d is %d, want %d", tt.name, tt.x, tt.y, tt.z, got, tt.want)
+		}
+	}
+}
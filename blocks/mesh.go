@@ -1,48 +1,95 @@
 package blocks
 
 import (
-	"fmt"
-	"math"
-
 	"github.com/go-gl/mathgl/mgl64"
 )
 
 type CulledMesher struct {
-	Mesh     MeshRenderer
-	Blocks   BlockContainer
+	Meshes   ChunkMeshes
+	Blocks   BlockStorage
 	LightMap LightMapContainer
 	Offset   mgl64.Vec3
 
-	XMinus, XPlus *Chunk
-	YMinus, YPlus *Chunk
-	ZMinus, ZPlus *Chunk
+	Neighbours
 }
 
-func (cm *CulledMesher) GetBlockColor(x, y, z int, bt BlockType) mgl64.Vec3 {
-	base := 0.86
+func NewCulledMesher() Mesher {
+	return &CulledMesher{}
+}
 
-	tl := 15.0
-	if x >= 0 && y >= 0 && z >= 0 && x < ChunkSize && y < ChunkSize && z < ChunkSize {
-		tl = math.Max(float64(cm.LightMap.Torchlight(x, y, z)), float64(cm.LightMap.Sunlight(x, y, z)))
+func (cm *CulledMesher) BuildMesh(meshes ChunkMeshes, blocks BlockStorage, lightMap LightMapContainer, offset mgl64.Vec3, n Neighbours) {
+	m := CulledMesher{
+		Meshes:     meshes,
+		Blocks:     blocks,
+		LightMap:   lightMap,
+		Offset:     offset,
+		Neighbours: n,
 	}
 
-	lightColor := math.Pow(tl/16.0, 1.4) + base
+	m.Update()
+}
+
+// GetBlockColor returns the unlit colour of the given face of the block type. Lighting is applied by
+// the shader so that sunlight can follow the time of day without rebuilding meshes.
+func (cm *CulledMesher) GetBlockColor(bt BlockType, face Face) mgl64.Vec3 {
+	return bt.Definition().Colors[face]
+}
 
-	switch bt {
-	case Grass:
-		return mgl64.Vec3{0.094 * lightColor, 0.568 * lightColor, 0.109 * lightColor}
-	case Stone:
-		return mgl64.Vec3{0.423, 0.478, 0.537}
+// GetBlockLight returns the torch and sunlight values at the given position, which may lie just outside
+// of the chunk in one of its neighbours.
+func (cm *CulledMesher) GetBlockLight(x, y, z int) (uint8, uint8) {
+	return lightLevels(cm.LightMap, cm.Neighbours, x, y, z)
+}
+
+func (cm *CulledMesher) setFaceColor(mesh MeshRenderer, face Face, x, y, z int, bt BlockType) {
+	mesh.SetColor(cm.GetBlockColor(bt, face))
+	mesh.SetAlpha(1 - bt.Definition().Translucency)
+	mesh.SetLight(cm.GetBlockLight(x, y, z))
+}
+
+// lightLevels returns the torch and sunlight values at the given position. Positions outside of the
+// chunk are read from the neighbour they fall in, or treated as open sky when it is not loaded.
+func lightLevels(lm LightMapContainer, n Neighbours, x, y, z int) (uint8, uint8) {
+	var neighbour *Chunk
+
+	switch {
+	case x < 0:
+		neighbour, x = n.XMinus, ChunkSize-1
+	case x >= ChunkSize:
+		neighbour, x = n.XPlus, 0
+	case y < 0:
+		neighbour, y = n.YMinus, ChunkSize-1
+	case y >= ChunkSize:
+		neighbour, y = n.YPlus, 0
+	case z < 0:
+		neighbour, z = n.ZMinus, ChunkSize-1
+	case z >= ChunkSize:
+		neighbour, z = n.ZPlus, 0
 	default:
-		return mgl64.Vec3{} // TODO: handle error
+		return lm.Torchlight(x, y, z), lm.Sunlight(x, y, z)
+	}
+
+	if neighbour == nil {
+		return 0, MaxLightLevel
 	}
+
+	return neighbour.lightMap.Torchlight(x, y, z), neighbour.lightMap.Sunlight(x, y, z)
+}
+
+// faceHidden reports whether a face of bt is hidden by the neighbouring block. Opaque blocks hide every
+// face next to them, and faces between two blocks of the same transparent type, or between two fluids
+// of any level, are culled so that the inside of a body of water or glass is not drawn.
+func faceHidden(bt, neighbour BlockType) bool {
+	def, nDef := bt.Definition(), neighbour.Definition()
+
+	return !nDef.Transparent || bt == neighbour || (def.FluidLevel > 0 && nDef.FluidLevel > 0)
 }
 
 func (cm *CulledMesher) Update() {
 	for x := 0.0; x < ChunkSize; x += 1 {
 		for y := 0.0; y < ChunkSize; y += 1 {
 			for z := 0.0; z < ChunkSize; z += 1 {
-				if bt := cm.Blocks.Lookup(int(x), int(y), int(z)); bt != Empty {
+				if bt := cm.Blocks.Lookup(int(x), int(y), int(z)); !bt.Definition().Invisible {
 					cm.createCube(x, y, z, bt)
 				}
 			}
@@ -62,148 +109,132 @@ func (cm *CulledMesher) createCube(x, y, z float64, bt BlockType) {
 
 	var v1, v2, v3, v4, v5, v6, v7, v8 uint32
 
+	mesh := cm.Meshes.For(bt)
+
 	// Front
-	if (z == ChunkSize-1) || (z < ChunkSize-1 && cm.Blocks.Lookup(int(x), int(y), int(z)+1) == Empty) {
+	if (z == ChunkSize-1) || (z < ChunkSize-1 && !faceHidden(bt, cm.Blocks.Lookup(int(x), int(y), int(z)+1))) {
 		addSide := true
 
 		if z == ChunkSize-1 {
-			addSide = cm.ZPlus != nil && cm.ZPlus.blocks.Lookup(int(x), int(y), 0) == Empty
+			addSide = cm.ZPlus != nil && !faceHidden(bt, cm.ZPlus.blocks.Lookup(int(x), int(y), 0))
 		}
 
 		if addSide {
 			// TODO: do we need to add or subtract 1 here? is this whats skewing stuff?
-			cm.Mesh.SetColor(cm.GetBlockColor(int(x), int(y), int(z)+1, bt))
+			cm.setFaceColor(mesh, FaceFront, int(x), int(y), int(z)+1, bt)
 
-			v1 = cm.Mesh.AddVertex(p1)
-			v2 = cm.Mesh.AddVertex(p2)
-			v3 = cm.Mesh.AddVertex(p3)
-			v4 = cm.Mesh.AddVertex(p4)
+			v1 = mesh.AddVertex(p1)
+			v2 = mesh.AddVertex(p2)
+			v3 = mesh.AddVertex(p3)
+			v4 = mesh.AddVertex(p4)
 
-			cm.Mesh.AddTriangle(v1, v2, v3)
-			cm.Mesh.AddTriangle(v1, v3, v4)
+			mesh.AddTriangle(v1, v2, v3)
+			mesh.AddTriangle(v1, v3, v4)
 		}
 	}
 
 	// Back
-	if z == 0 || (z > 0 && cm.Blocks.Lookup(int(x), int(y), int(z)-1) == Empty) {
+	if z == 0 || (z > 0 && !faceHidden(bt, cm.Blocks.Lookup(int(x), int(y), int(z)-1))) {
 		addSide := true
 
 		if z == 0 {
-			addSide = cm.ZMinus != nil && cm.ZMinus.blocks.Lookup(int(x), int(y), ChunkSize-1) == Empty
-			if addSide {
-				fmt.Println(addSide)
-			}
+			addSide = cm.ZMinus != nil && !faceHidden(bt, cm.ZMinus.blocks.Lookup(int(x), int(y), ChunkSize-1))
 		}
 
 		if addSide {
-			cm.Mesh.SetColor(cm.GetBlockColor(int(x), int(y), int(z)-1, bt))
+			cm.setFaceColor(mesh, FaceBack, int(x), int(y), int(z)-1, bt)
 
-			v5 = cm.Mesh.AddVertex(p5)
-			v6 = cm.Mesh.AddVertex(p6)
-			v7 = cm.Mesh.AddVertex(p7)
-			v8 = cm.Mesh.AddVertex(p8)
+			v5 = mesh.AddVertex(p5)
+			v6 = mesh.AddVertex(p6)
+			v7 = mesh.AddVertex(p7)
+			v8 = mesh.AddVertex(p8)
 
-			cm.Mesh.AddTriangle(v5, v6, v7)
-			cm.Mesh.AddTriangle(v5, v7, v8)
+			mesh.AddTriangle(v5, v6, v7)
+			mesh.AddTriangle(v5, v7, v8)
 		}
 	}
 
 	// Right
-	if (x == ChunkSize-1) || (x < ChunkSize-1 && cm.Blocks.Lookup(int(x)+1, int(y), int(z)) == Empty) {
+	if (x == ChunkSize-1) || (x < ChunkSize-1 && !faceHidden(bt, cm.Blocks.Lookup(int(x)+1, int(y), int(z)))) {
 		addSide := true
 
 		if x == ChunkSize-1 {
-			addSide = cm.XPlus != nil && cm.XPlus.blocks.Lookup(0, int(y), int(z)) == Empty
-			if addSide {
-				fmt.Println(addSide)
-				if addSide {
-					fmt.Println(addSide)
-				}
-			}
+			addSide = cm.XPlus != nil && !faceHidden(bt, cm.XPlus.blocks.Lookup(0, int(y), int(z)))
 		}
 
 		if addSide {
-			cm.Mesh.SetColor(cm.GetBlockColor(int(x)+1, int(y), int(z), bt))
+			cm.setFaceColor(mesh, FaceRight, int(x)+1, int(y), int(z), bt)
 
-			v2 = cm.Mesh.AddVertex(p2)
-			v5 = cm.Mesh.AddVertex(p5)
-			v8 = cm.Mesh.AddVertex(p8)
-			v3 = cm.Mesh.AddVertex(p3)
+			v2 = mesh.AddVertex(p2)
+			v5 = mesh.AddVertex(p5)
+			v8 = mesh.AddVertex(p8)
+			v3 = mesh.AddVertex(p3)
 
-			cm.Mesh.AddTriangle(v2, v5, v8)
-			cm.Mesh.AddTriangle(v2, v8, v3)
+			mesh.AddTriangle(v2, v5, v8)
+			mesh.AddTriangle(v2, v8, v3)
 		}
 	}
 
 	// Left
-	if x == 0 || (x > 0 && cm.Blocks.Lookup(int(x)-1, int(y), int(z)) == Empty) {
+	if x == 0 || (x > 0 && !faceHidden(bt, cm.Blocks.Lookup(int(x)-1, int(y), int(z)))) {
 		addSide := true
 
 		if x == 0 {
-			addSide = cm.XMinus != nil && cm.XMinus.blocks.Lookup(ChunkSize-1, int(y), int(z)) == Empty
-			if addSide {
-				fmt.Println(addSide)
-			}
+			addSide = cm.XMinus != nil && !faceHidden(bt, cm.XMinus.blocks.Lookup(ChunkSize-1, int(y), int(z)))
 		}
 
 		if addSide {
-			cm.Mesh.SetColor(cm.GetBlockColor(int(x)-1, int(y), int(z), bt))
+			cm.setFaceColor(mesh, FaceLeft, int(x)-1, int(y), int(z), bt)
 
-			v6 = cm.Mesh.AddVertex(p6)
-			v1 = cm.Mesh.AddVertex(p1)
-			v4 = cm.Mesh.AddVertex(p4)
-			v7 = cm.Mesh.AddVertex(p7)
+			v6 = mesh.AddVertex(p6)
+			v1 = mesh.AddVertex(p1)
+			v4 = mesh.AddVertex(p4)
+			v7 = mesh.AddVertex(p7)
 
-			cm.Mesh.AddTriangle(v6, v1, v4)
-			cm.Mesh.AddTriangle(v6, v4, v7)
+			mesh.AddTriangle(v6, v1, v4)
+			mesh.AddTriangle(v6, v4, v7)
 		}
 	}
 
 	// Top
-	if (y == ChunkSize-1) || (y < ChunkSize-1 && cm.Blocks.Lookup(int(x), int(y)+1, int(z)) == Empty) {
+	if (y == ChunkSize-1) || (y < ChunkSize-1 && !faceHidden(bt, cm.Blocks.Lookup(int(x), int(y)+1, int(z)))) {
 		addSide := true
 
 		if y == ChunkSize-1 {
-			addSide = cm.YPlus != nil && cm.YPlus.blocks.Lookup(int(x), 0, int(z)) == Empty
-			if addSide {
-				fmt.Println(addSide)
-			}
+			addSide = cm.YPlus != nil && !faceHidden(bt, cm.YPlus.blocks.Lookup(int(x), 0, int(z)))
 		}
 
 		if addSide {
-			cm.Mesh.SetColor(cm.GetBlockColor(int(x), int(y)+1, int(z), bt))
+			cm.setFaceColor(mesh, FaceTop, int(x), int(y)+1, int(z), bt)
 
-			v4 = cm.Mesh.AddVertex(p4)
-			v3 = cm.Mesh.AddVertex(p3)
-			v8 = cm.Mesh.AddVertex(p8)
-			v7 = cm.Mesh.AddVertex(p7)
+			v4 = mesh.AddVertex(p4)
+			v3 = mesh.AddVertex(p3)
+			v8 = mesh.AddVertex(p8)
+			v7 = mesh.AddVertex(p7)
 
-			cm.Mesh.AddTriangle(v4, v3, v8)
-			cm.Mesh.AddTriangle(v4, v8, v7)
+			mesh.AddTriangle(v4, v3, v8)
+			mesh.AddTriangle(v4, v8, v7)
 		}
 	}
 
 	// Bottom
-	if y == 0 || (y > 0 && cm.Blocks.Lookup(int(x), int(y)-1, int(z)) == Empty) {
+	if y == 0 || (y > 0 && !faceHidden(bt, cm.Blocks.Lookup(int(x), int(y)-1, int(z)))) {
 		addSide := true
 
 		if y == 0 {
-			addSide = cm.YMinus != nil && cm.YMinus.blocks.Lookup(int(x), ChunkSize-1, int(z)) == Empty
-			if addSide {
-				fmt.Println(addSide)
-			}
+			addSide = cm.YMinus != nil && !faceHidden(bt, cm.YMinus.blocks.Lookup(int(x), ChunkSize-1, int(z)))
 		}
 
 		if addSide {
-			cm.Mesh.SetColor(cm.GetBlockColor(int(x), int(y)-1, int(z), bt))
+			cm.setFaceColor(mesh, FaceBottom, int(x), int(y)-1, int(z), bt)
 
-			v6 = cm.Mesh.AddVertex(p6)
-			v5 = cm.Mesh.AddVertex(p5)
-			v2 = cm.Mesh.AddVertex(p2)
-			v1 = cm.Mesh.AddVertex(p1)
+			v6 = mesh.AddVertex(p6)
+			v5 = mesh.AddVertex(p5)
+			v2 = mesh.AddVertex(p2)
+			v1 = mesh.AddVertex(p1)
 
-			cm.Mesh.AddTriangle(v6, v5, v2)
-			cm.Mesh.AddTriangle(v6, v2, v1)
+			mesh.AddTriangle(v6, v5, v2)
+			mesh.AddTriangle(v6, v2, v1)
 		}
 	}
 }
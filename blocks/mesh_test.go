@@ -0,0 +1,148 @@
+package blocks_test
+
+import (
+	"testing"
+
+	"github.com/nickbryan/voxel/blocks"
+	"github.com/nickbryan/voxel/blocks/meshrec"
+	"github.com/nickbryan/voxel/blocks/meshtest"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+// fill is a TerrainGenerator that fills the whole chunk with one block type.
+type fill blocks.BlockType
+
+func (f fill) Generate(bs blocks.BlockStorage, _ mgl64.Vec3) {
+	for x := 0; x < blocks.ChunkSize; x++ {
+		for y := 0; y < blocks.ChunkSize; y++ {
+			for z := 0; z < blocks.ChunkSize; z++ {
+				bs.Set(x, y, z, blocks.BlockType(f))
+			}
+		}
+	}
+}
+
+func filledChunk(bt blocks.BlockType) *blocks.Chunk {
+	ch := &blocks.Chunk{Generator: fill(bt)}
+	ch.Setup()
+
+	return ch
+}
+
+// surrounded returns neighbours on every side filled with bt.
+func surrounded(bt blocks.BlockType) blocks.Neighbours {
+	return blocks.Neighbours{
+		XMinus: filledChunk(bt), XPlus: filledChunk(bt),
+		YMinus: filledChunk(bt), YPlus: filledChunk(bt),
+		ZMinus: filledChunk(bt), ZPlus: filledChunk(bt),
+	}
+}
+
+var meshers = map[string]blocks.MesherFactory{
+	"culled": blocks.NewCulledMesher,
+	"greedy": blocks.NewGreedyMesher,
+}
+
+func buildMesh(newMesher blocks.MesherFactory, bs blocks.BlockStorage, n blocks.Neighbours) *meshrec.Recorder {
+	r := meshrec.NewRecorder()
+	lm := make(blocks.LightMapContainer, blocks.ChunkSizeCubed)
+
+	newMesher().BuildMesh(blocks.ChunkMeshes{Opaque: r, Transparent: meshrec.NewRecorder()}, bs, lm, mgl64.Vec3{}, n)
+
+	return r
+}
+
+func solidIn(bs blocks.BlockStorage) func(x, y, z int) bool {
+	return func(x, y, z int) bool {
+		if x < 0 || y < 0 || z < 0 || x >= blocks.ChunkSize || y >= blocks.ChunkSize || z >= blocks.ChunkSize {
+			return false
+		}
+
+		return bs.Lookup(x, y, z) != blocks.Empty
+	}
+}
+
+func TestMeshBorderCulling(t *testing.T) {
+	corner := blocks.NewPaletteContainer(blocks.Empty)
+	corner.Set(0, 0, 0, blocks.Stone)
+
+	// Faces against the border towards the origin only exist once a neighbour with room for them is
+	// loaded; the other three always face empty blocks inside the chunk.
+	tests := []struct {
+		name       string
+		neighbours blocks.Neighbours
+		want       int
+		wantFacing map[blocks.Face]int
+	}{
+		{
+			name:       "no neighbours",
+			want:       3,
+			wantFacing: map[blocks.Face]int{blocks.FaceLeft: 0, blocks.FaceBottom: 0, blocks.FaceBack: 0, blocks.FaceRight: 1, blocks.FaceTop: 1, blocks.FaceFront: 1},
+		},
+		{
+			name:       "empty neighbours",
+			neighbours: surrounded(blocks.Empty),
+			want:       6,
+			wantFacing: map[blocks.Face]int{blocks.FaceLeft: 1, blocks.FaceBottom: 1, blocks.FaceBack: 1, blocks.FaceRight: 1, blocks.FaceTop: 1, blocks.FaceFront: 1},
+		},
+		{
+			name:       "solid neighbours",
+			neighbours: surrounded(blocks.Stone),
+			want:       3,
+			wantFacing: map[blocks.Face]int{blocks.FaceLeft: 0, blocks.FaceBottom: 0, blocks.FaceBack: 0, blocks.FaceRight: 1, blocks.FaceTop: 1, blocks.FaceFront: 1},
+		},
+	}
+
+	for name, newMesher := range meshers {
+		for _, tt := range tests {
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				r := buildMesh(newMesher, corner, tt.neighbours)
+
+				meshtest.AssertFaces(t, r, tt.want)
+				meshtest.AssertWinding(t, r, solidIn(corner))
+
+				for face, want := range tt.wantFacing {
+					meshtest.AssertFacing(t, r, face, want)
+				}
+			})
+		}
+	}
+}
+
+func TestMeshSolidChunk(t *testing.T) {
+	solid := blocks.NewPaletteContainer(blocks.Stone)
+	side := blocks.ChunkSize * blocks.ChunkSize
+
+	tests := []struct {
+		name       string
+		mesher     blocks.MesherFactory
+		neighbours blocks.Neighbours
+		perFace    int
+	}{
+		{"culled/no neighbours", blocks.NewCulledMesher, blocks.Neighbours{}, 0},
+		{"culled/solid neighbours", blocks.NewCulledMesher, surrounded(blocks.Stone), 0},
+		{"culled/empty neighbours", blocks.NewCulledMesher, surrounded(blocks.Empty), side},
+		{"greedy/no neighbours", blocks.NewGreedyMesher, blocks.Neighbours{}, 0},
+		{"greedy/solid neighbours", blocks.NewGreedyMesher, surrounded(blocks.Stone), 0},
+		{"greedy/empty neighbours", blocks.NewGreedyMesher, surrounded(blocks.Empty), 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := buildMesh(tt.mesher, solid, tt.neighbours)
+
+			meshtest.AssertFaces(t, r, 6*tt.perFace)
+			meshtest.AssertWinding(t, r, solidIn(solid))
+
+			for face := blocks.FaceLeft; face <= blocks.FaceFront; face++ {
+				meshtest.AssertFacing(t, r, face, tt.perFace)
+			}
+
+			if tt.perFace > 0 {
+				half := float64(blocks.ChunkSize) - 0.5
+				meshtest.AssertBounds(t, r, mgl64.Vec3{-0.5, -0.5, -0.5}, mgl64.Vec3{half, half, half})
+			}
+		})
+	}
+}
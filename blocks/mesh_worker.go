@@ -0,0 +1,135 @@
+package blocks
+
+import (
+	"sync"
+)
+
+// MeshWorkers rebuilds chunk meshes on a pool of background goroutines. Each rebuild is written into a
+// fresh mesh which is only swapped into the chunk once it has been uploaded, so the previous mesh keeps
+// rendering until its replacement is ready.
+type MeshWorkers struct {
+	createMeshes func() ChunkMeshes
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queue   []*Chunk
+	pending map[*Chunk]bool
+	stopped bool
+
+	// building holds the chunks being rebuilt, set to true when another rebuild is requested before the
+	// current one finishes. A chunk is only ever built by one worker at a time so that an older build
+	// cannot finish last and replace a newer mesh.
+	building map[*Chunk]bool
+
+	wg sync.WaitGroup
+}
+
+// NewMeshWorkers starts n workers that build into meshes created by createMeshes.
+func NewMeshWorkers(n int, createMeshes func() ChunkMeshes) *MeshWorkers {
+	w := &MeshWorkers{
+		createMeshes: createMeshes,
+		pending:      make(map[*Chunk]bool),
+		building:     make(map[*Chunk]bool),
+	}
+
+	w.cond = sync.NewCond(&w.mu)
+
+	if n < 1 {
+		n = 1
+	}
+
+	w.wg.Add(n)
+	for i := 0; i < n; i++ {
+		go w.work()
+	}
+
+	return w
+}
+
+// Enqueue schedules a rebuild of the chunk's mesh. It does not block, and a chunk that is already
+// waiting to be rebuilt is only queued once. A chunk that is being rebuilt is queued again once that
+// rebuild finishes.
+func (w *MeshWorkers) Enqueue(c *Chunk) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.stopped || w.pending[c] {
+		return
+	}
+
+	if _, ok := w.building[c]; ok {
+		w.building[c] = true
+		return
+	}
+
+	w.push(c)
+}
+
+// push queues the chunk. w.mu must be held.
+func (w *MeshWorkers) push(c *Chunk) {
+	w.pending[c] = true
+	w.queue = append(w.queue, c)
+	w.cond.Signal()
+}
+
+// Stop discards the queued rebuilds and waits for the workers to finish the ones in progress and exit.
+// Chunks enqueued afterwards are ignored.
+func (w *MeshWorkers) Stop() {
+	w.mu.Lock()
+	w.stopped = true
+	w.queue = nil
+	w.pending = make(map[*Chunk]bool)
+	w.cond.Broadcast()
+	w.mu.Unlock()
+
+	w.wg.Wait()
+}
+
+func (w *MeshWorkers) work() {
+	defer w.wg.Done()
+
+	for {
+		w.mu.Lock()
+		for len(w.queue) == 0 && !w.stopped {
+			w.cond.Wait()
+		}
+
+		if w.stopped {
+			w.mu.Unlock()
+			return
+		}
+
+		c := w.queue[0]
+		w.queue[0] = nil
+		w.queue = w.queue[1:]
+
+		// Clear the pending flag before building so that changes made during the rebuild queue
+		// another one rather than being lost.
+		delete(w.pending, c)
+		w.building[c] = false
+		w.mu.Unlock()
+
+		w.rebuild(c)
+
+		w.mu.Lock()
+		again := w.building[c]
+		delete(w.building, c)
+		if again && !w.stopped {
+			w.push(c)
+		}
+		w.mu.Unlock()
+	}
+}
+
+func (w *MeshWorkers) rebuild(c *Chunk) {
+	if c.isUnloaded() {
+		return
+	}
+
+	meshes := w.createMeshes()
+
+	c.BuildMesh(meshes)
+	meshes.Finish()
+
+	c.swapMeshes(meshes)
+}
@@ -0,0 +1,67 @@
+package blocks
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+// versionMesh is a mesh that remembers which version of the chunk it was built from.
+type versionMesh struct {
+	countingMesh
+	version int64
+}
+
+// slowMesher takes a while to build and records the chunk version each build saw, along with whether
+// two builds of the chunk ever overlapped.
+type slowMesher struct {
+	version  int64
+	building int64
+	overlap  int64
+}
+
+func (m *slowMesher) BuildMesh(meshes ChunkMeshes, _ BlockStorage, _ LightMapContainer, _ mgl64.Vec3, _ Neighbours) {
+	if atomic.AddInt64(&m.building, 1) > 1 {
+		atomic.StoreInt64(&m.overlap, 1)
+	}
+	defer atomic.AddInt64(&m.building, -1)
+
+	meshes.Opaque.(*versionMesh).version = atomic.LoadInt64(&m.version)
+	time.Sleep(10 * time.Millisecond)
+}
+
+func TestMeshWorkersRebuildEachChunkOnce(t *testing.T) {
+	b := &countingBackend{}
+	m := &slowMesher{}
+	c := &Chunk{Mesher: m}
+
+	w := NewMeshWorkers(4, func() ChunkMeshes {
+		return ChunkMeshes{Opaque: &versionMesh{countingMesh: countingMesh{b: b}}, Transparent: b.CreateMesh()}
+	})
+	defer w.Stop()
+
+	// Edit the chunk and ask for a rebuild several times per build, so that most requests arrive while
+	// a build is in progress.
+	for i := 0; i < 100; i++ {
+		atomic.AddInt64(&m.version, 1)
+		w.Enqueue(c)
+
+		time.Sleep(time.Millisecond)
+	}
+
+	want := atomic.LoadInt64(&m.version)
+
+	// Whatever order the builds finish in, the chunk has to end up showing the last edit.
+	waitFor(t, "the chunk to show its latest version", func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		return c.Meshes != nil && c.Meshes.Opaque.(*versionMesh).version == want
+	})
+
+	if atomic.LoadInt64(&m.overlap) != 0 {
+		t.Error("two workers built the same chunk at once")
+	}
+}
@@ -0,0 +1,194 @@
+// Package meshrec provides a MeshRenderer and RenderBackend that record geometry in memory, for
+// exporting meshes and for testing meshers and chunk management without a GL context.
+package meshrec
+
+import (
+	"sync"
+
+	"github.com/nickbryan/voxel/blocks"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+// Vertex is a recorded vertex along with the attributes that were active when it was added.
+type Vertex struct {
+	Pos                  mgl64.Vec3
+	Color                mgl64.Vec3
+	Alpha                float64
+	Torchlight, Sunlight uint8
+}
+
+// Recorder is a blocks.MeshRenderer that records everything written to it.
+type Recorder struct {
+	Vertices  []Vertex
+	Triangles [][3]uint32
+
+	Transparent bool
+	Finished    bool
+	TornDown    bool
+
+	current Vertex
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{current: Vertex{Alpha: 1}}
+}
+
+func (r *Recorder) AddVertex(p mgl64.Vec3) uint32 {
+	v := r.current
+	v.Pos = p
+	r.Vertices = append(r.Vertices, v)
+
+	return uint32(len(r.Vertices) - 1)
+}
+
+func (r *Recorder) AddTriangle(v1, v2, v3 uint32) {
+	r.Triangles = append(r.Triangles, [3]uint32{v1, v2, v3})
+}
+
+func (r *Recorder) SetColor(c mgl64.Vec3) {
+	r.current.Color = c
+}
+
+func (r *Recorder) SetAlpha(a float64) {
+	r.current.Alpha = a
+}
+
+func (r *Recorder) SetLight(torchlight, sunlight uint8) {
+	r.current.Torchlight, r.current.Sunlight = torchlight, sunlight
+}
+
+func (r *Recorder) Finish() {
+	r.Finished = true
+}
+
+func (r *Recorder) TearDown() {
+	r.TornDown = true
+}
+
+// Empty reports whether the recorder has no triangles.
+func (r *Recorder) Empty() bool {
+	return len(r.Triangles) == 0
+}
+
+// Triangle returns the positions of the corners of the i'th triangle.
+func (r *Recorder) Triangle(i int) (a, b, c mgl64.Vec3) {
+	t := r.Triangles[i]
+
+	return r.Vertices[t[0]].Pos, r.Vertices[t[1]].Pos, r.Vertices[t[2]].Pos
+}
+
+// Normal returns the unit normal of the i'th triangle, taking its corners to be counter clockwise when
+// seen from the front. It is zero for degenerate triangles.
+func (r *Recorder) Normal(i int) mgl64.Vec3 {
+	a, b, c := r.Triangle(i)
+
+	n := b.Sub(a).Cross(c.Sub(a))
+	if n.Len() == 0 {
+		return n
+	}
+
+	return n.Normalize()
+}
+
+// Faces returns the number of block faces recorded, assuming each is made of two triangles.
+func (r *Recorder) Faces() int {
+	return len(r.Triangles) / 2
+}
+
+// Bounds returns the smallest box containing every vertex used by a triangle.
+func (r *Recorder) Bounds() (min, max mgl64.Vec3) {
+	for i, t := range r.Triangles {
+		for j, v := range t {
+			p := r.Vertices[v].Pos
+			if i == 0 && j == 0 {
+				min, max = p, p
+				continue
+			}
+
+			for k := 0; k < 3; k++ {
+				if p[k] < min[k] {
+					min[k] = p[k]
+				}
+				if p[k] > max[k] {
+					max[k] = p[k]
+				}
+			}
+		}
+	}
+
+	return min, max
+}
+
+// Facing returns the indexes of the triangles facing the given face direction.
+func (r *Recorder) Facing(face blocks.Face) []int {
+	want := FaceNormal(face)
+
+	var tris []int
+	for i := range r.Triangles {
+		if r.Normal(i).ApproxEqual(want) {
+			tris = append(tris, i)
+		}
+	}
+
+	return tris
+}
+
+// FaceNormal returns the outward unit normal of a block face.
+func FaceNormal(face blocks.Face) mgl64.Vec3 {
+	var n mgl64.Vec3
+
+	axis := int(face) / 2
+	n[axis] = -1
+	if face%2 == 1 {
+		n[axis] = 1
+	}
+
+	return n
+}
+
+// Backend is a blocks.RenderBackend that creates Recorders and keeps every one it creates.
+type Backend struct {
+	mu     sync.Mutex
+	meshes []*Recorder
+}
+
+func (b *Backend) CreateMesh() blocks.MeshRenderer {
+	return b.create(false)
+}
+
+func (b *Backend) CreateTransparentMesh() blocks.MeshRenderer {
+	return b.create(true)
+}
+
+func (b *Backend) create(transparent bool) *Recorder {
+	r := NewRecorder()
+	r.Transparent = transparent
+
+	b.mu.Lock()
+	b.meshes = append(b.meshes, r)
+	b.mu.Unlock()
+
+	return r
+}
+
+// Meshes returns every mesh created so far.
+func (b *Backend) Meshes() []*Recorder {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]*Recorder(nil), b.meshes...)
+}
+
+// Live returns the meshes that have been finished and not yet torn down, which are the ones chunks are
+// currently showing.
+func (b *Backend) Live() []*Recorder {
+	var live []*Recorder
+	for _, r := range b.Meshes() {
+		if r.Finished && !r.TornDown {
+			live = append(live, r)
+		}
+	}
+
+	return live
+}
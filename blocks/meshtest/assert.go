@@ -0,0 +1,100 @@
+// Package meshtest provides helpers for checking the geometry recorded by a meshrec.Recorder.
+package meshtest
+
+import (
+	"math"
+	"testing"
+
+	"github.com/nickbryan/voxel/blocks"
+	"github.com/nickbryan/voxel/blocks/meshrec"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+// AssertFaces fails the test unless the recorder holds want block faces.
+func AssertFaces(t testing.TB, r *meshrec.Recorder, want int) {
+	t.Helper()
+
+	if len(r.Triangles)%2 != 0 {
+		t.Errorf("recorded %d triangles, which is not a whole number of faces", len(r.Triangles))
+	}
+
+	if got := r.Faces(); got != want {
+		t.Errorf("recorded %d faces, want %d", got, want)
+	}
+}
+
+// AssertFacing fails the test unless want block faces point in the given direction.
+func AssertFacing(t testing.TB, r *meshrec.Recorder, face blocks.Face, want int) {
+	t.Helper()
+
+	if got := len(r.Facing(face)) / 2; got != want {
+		t.Errorf("recorded %d faces facing %v, want %d", got, meshrec.FaceNormal(face), want)
+	}
+}
+
+// AssertWinding fails the test unless every triangle is wound counter clockwise when seen from outside
+// the block it belongs to, so that back face culling keeps it. solid reports whether there is a block
+// at the given world block coordinates.
+func AssertWinding(t testing.TB, r *meshrec.Recorder, solid func(x, y, z int) bool) {
+	t.Helper()
+
+	for i := range r.Triangles {
+		n := r.Normal(i)
+		if !isAxis(n) {
+			t.Errorf("triangle %d has normal %v, which is not along an axis", i, n)
+			continue
+		}
+
+		a, b, c := r.Triangle(i)
+		centre := a.Add(b).Add(c).Mul(1.0 / 3)
+
+		// Blocks are centred on whole coordinates, so step a quarter block behind the face to find
+		// the block that owns it.
+		p := centre.Sub(n.Mul(blocks.BlockRenderSize / 2))
+		x, y, z := int(math.Round(p.X())), int(math.Round(p.Y())), int(math.Round(p.Z()))
+
+		if !solid(x, y, z) {
+			t.Errorf("triangle %d faces %v into block %d,%d,%d, which is empty; its winding is reversed", i, n, x, y, z)
+		}
+	}
+}
+
+// AssertBounds fails the test unless the bounding box of the recorded triangles is min to max.
+func AssertBounds(t testing.TB, r *meshrec.Recorder, min, max mgl64.Vec3) {
+	t.Helper()
+
+	gotMin, gotMax := r.Bounds()
+	if !gotMin.ApproxEqual(min) || !gotMax.ApproxEqual(max) {
+		t.Errorf("recorded bounds %v to %v, want %v to %v", gotMin, gotMax, min, max)
+	}
+}
+
+// AssertFaceColor fails the test unless every vertex of every triangle facing the given direction has
+// the colour want.
+func AssertFaceColor(t testing.TB, r *meshrec.Recorder, face blocks.Face, want mgl64.Vec3) {
+	t.Helper()
+
+	for _, i := range r.Facing(face) {
+		for _, v := range r.Triangles[i] {
+			if got := r.Vertices[v].Color; !got.ApproxEqual(want) {
+				t.Errorf("triangle %d facing %v has colour %v, want %v", i, meshrec.FaceNormal(face), got, want)
+				break
+			}
+		}
+	}
+}
+
+func isAxis(n mgl64.Vec3) bool {
+	axes := 0
+	for _, c := range n {
+		switch {
+		case math.Abs(math.Abs(c)-1) < 1e-9:
+			axes++
+		case math.Abs(c) > 1e-9:
+			return false
+		}
+	}
+
+	return axes == 1
+}
@@ -0,0 +1,180 @@
+package blocks
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// BlockStorage holds the blocks of a single chunk.
+type BlockStorage interface {
+	Lookup(x, y, z int) BlockType
+	Set(x, y, z int, bt BlockType)
+}
+
+// PaletteContainer stores a chunk's blocks as indexes into a palette of the block types it contains,
+// packed into as few bits as the size of the palette allows. A chunk made up of a single block type,
+// such as one that is all air, needs no indexes at all. Calls to Set are serialised, but callers must
+// not Lookup while another goroutine calls Set; ChunkManager guards every chunk's blocks with its world
+// lock.
+type PaletteContainer struct {
+	state atomic.Value // *paletteState
+
+	// mu serialises calls to Set, which may replace the state as the palette grows.
+	mu     sync.Mutex
+	used   int
+	counts []int
+}
+
+type paletteState struct {
+	// bits is the width of each index: 0 while the chunk holds a single block type, and otherwise a
+	// power of two so that an index never straddles two words of data.
+	bits    uint
+	palette []BlockType
+	data    []uint64
+}
+
+// NewPaletteContainer creates a container filled with bt.
+func NewPaletteContainer(bt BlockType) *PaletteContainer {
+	p := &PaletteContainer{}
+	p.reset(bt)
+
+	return p
+}
+
+func (p *PaletteContainer) Lookup(x, y, z int) BlockType {
+	s := p.load()
+	if s.bits == 0 {
+		return s.palette[0]
+	}
+
+	return s.palette[s.index(idx(x, y, z))]
+}
+
+func (p *PaletteContainer) Set(x, y, z int, bt BlockType) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	i := idx(x, y, z)
+
+	s := p.load()
+	old := 0
+	if s.bits > 0 {
+		old = s.index(i)
+	}
+
+	if s.palette[old] == bt {
+		return
+	}
+
+	v := p.paletteIndex(bt)
+	p.counts[old]--
+	p.counts[v]++
+
+	if p.counts[v] == ChunkSizeCubed {
+		p.reset(bt)
+		return
+	}
+
+	p.load().setIndex(i, v)
+}
+
+// Clone returns an independent copy of the container.
+func (p *PaletteContainer) Clone() *PaletteContainer {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s := p.load()
+	c := &PaletteContainer{
+		used:   p.used,
+		counts: append([]int(nil), p.counts...),
+	}
+
+	c.state.Store(&paletteState{
+		bits:    s.bits,
+		palette: append([]BlockType(nil), s.palette...),
+		data:    append([]uint64(nil), s.data...),
+	})
+
+	return c
+}
+
+func (p *PaletteContainer) load() *paletteState {
+	return p.state.Load().(*paletteState)
+}
+
+func (p *PaletteContainer) reset(bt BlockType) {
+	p.used = 1
+	p.counts = []int{ChunkSizeCubed}
+	p.state.Store(&paletteState{palette: []BlockType{bt}})
+}
+
+// paletteIndex returns the palette entry for bt, adding one if needed. Entries that are no longer used
+// by any block are reused before the palette is grown.
+func (p *PaletteContainer) paletteIndex(bt BlockType) int {
+	s := p.load()
+
+	for v := 0; v < p.used; v++ {
+		if s.palette[v] == bt {
+			return v
+		}
+	}
+
+	for v := 0; v < p.used; v++ {
+		if p.counts[v] == 0 {
+			s.palette[v] = bt
+			return v
+		}
+	}
+
+	if p.used == len(s.palette) {
+		s = p.grow()
+	}
+
+	s.palette[p.used] = bt
+	p.counts = append(p.counts, 0)
+	p.used++
+
+	return p.used - 1
+}
+
+// grow doubles the width of the indexes. The indexes are repacked into a new state so that a
+// concurrent Lookup always sees a palette and data of matching width.
+func (p *PaletteContainer) grow() *paletteState {
+	s := p.load()
+
+	bits := s.bits * 2
+	if bits == 0 {
+		bits = 1
+	}
+
+	ns := &paletteState{
+		bits:    bits,
+		palette: make([]BlockType, 1<<bits),
+		data:    make([]uint64, ChunkSizeCubed*int(bits)/64),
+	}
+	copy(ns.palette, s.palette)
+
+	if s.bits > 0 {
+		for i := 0; i < ChunkSizeCubed; i++ {
+			ns.setIndex(i, s.index(i))
+		}
+	}
+
+	p.state.Store(ns)
+
+	return ns
+}
+
+func (s *paletteState) index(i int) int {
+	bit := uint(i) * s.bits
+
+	return int(s.data[bit/64] >> (bit % 64) & (1<<s.bits - 1))
+}
+
+func (s *paletteState) setIndex(i, v int) {
+	bit := uint(i) * s.bits
+	w, shift := bit/64, bit%64
+	mask := uint64(1<<s.bits-1) << shift
+
+	s.data[w] = s.data[w]&^mask | uint64(v)<<shift
+}
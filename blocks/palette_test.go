@@ -0,0 +1,165 @@
+package blocks
+
+import (
+	"math/rand"
+	"testing"
+	"unsafe"
+)
+
+// chunkPatterns fill a chunk the way typical terrain does: entirely one block, a few layers, or a mix
+// of many block types with no structure at all.
+var chunkPatterns = []struct {
+	name  string
+	block func(rng *rand.Rand, x, y, z int) BlockType
+}{
+	{"uniform", func(_ *rand.Rand, _, _, _ int) BlockType {
+		return Stone
+	}},
+	{"few", func(_ *rand.Rand, _, y, _ int) BlockType {
+		switch {
+		case y < 10:
+			return Stone
+		case y < 13:
+			return Dirt
+		case y == 13:
+			return Grass
+		default:
+			return Empty
+		}
+	}},
+	{"noisy", func(rng *rand.Rand, _, _, _ int) BlockType {
+		return BlockType(rng.Intn(DefaultRegistry.Len()))
+	}},
+}
+
+func fillChunk(bs BlockStorage, block func(rng *rand.Rand, x, y, z int) BlockType) {
+	rng := rand.New(rand.NewSource(1))
+
+	for z := 0; z < ChunkSize; z++ {
+		for y := 0; y < ChunkSize; y++ {
+			for x := 0; x < ChunkSize; x++ {
+				bs.Set(x, y, z, block(rng, x, y, z))
+			}
+		}
+	}
+}
+
+// paletteBytes is the memory held by the container's state, excluding fixed overheads.
+func paletteBytes(p *PaletteContainer) int {
+	s := p.load()
+
+	return len(s.data)*8 + cap(s.palette)*int(unsafe.Sizeof(Empty)) + cap(p.counts)*int(unsafe.Sizeof(0))
+}
+
+func TestPaletteContainerMatchesBlockContainer(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+
+	p := NewPaletteContainer(Empty)
+	want := make(BlockContainer, ChunkSizeCubed)
+
+	check := func(stage string) {
+		t.Helper()
+
+		for z := 0; z < ChunkSize; z++ {
+			for y := 0; y < ChunkSize; y++ {
+				for x := 0; x < ChunkSize; x++ {
+					if got, w := p.Lookup(x, y, z), want.Lookup(x, y, z); got != w {
+						t.Fatalf("%s: block %d, %d, %d is %d, want %d", stage, x, y, z, got, w)
+					}
+				}
+			}
+		}
+	}
+
+	// Add block types one at a time so the indexes grow through every width.
+	for n := 2; n <= DefaultRegistry.Len(); n++ {
+		for i := 0; i < 2000; i++ {
+			x, y, z := rng.Intn(ChunkSize), rng.Intn(ChunkSize), rng.Intn(ChunkSize)
+			bt := BlockType(rng.Intn(n))
+
+			p.Set(x, y, z, bt)
+			want.Set(x, y, z, bt)
+		}
+	}
+	check("random")
+
+	clone, cloned := p.Clone(), append(BlockContainer(nil), want...)
+
+	// Filling the chunk with one type collapses it back to a single palette entry.
+	fillChunk(p, chunkPatterns[0].block)
+	fillChunk(want, chunkPatterns[0].block)
+	check("uniform")
+
+	if bits := p.load().bits; bits != 0 {
+		t.Errorf("uniform chunk uses %d bit indexes, want 0", bits)
+	}
+
+	p, want = clone, cloned
+	check("clone")
+}
+
+func BenchmarkPaletteContainerFill(b *testing.B) {
+	for _, pat := range chunkPatterns {
+		b.Run(pat.name, func(b *testing.B) {
+			b.ReportAllocs()
+
+			var p *PaletteContainer
+			for i := 0; i < b.N; i++ {
+				p = NewPaletteContainer(Empty)
+				fillChunk(p, pat.block)
+			}
+
+			b.ReportMetric(float64(paletteBytes(p)), "bytes/chunk")
+		})
+	}
+}
+
+func BenchmarkBlockContainerFill(b *testing.B) {
+	for _, pat := range chunkPatterns {
+		b.Run(pat.name, func(b *testing.B) {
+			b.ReportAllocs()
+
+			var c BlockContainer
+			for i := 0; i < b.N; i++ {
+				c = make(BlockContainer, ChunkSizeCubed)
+				fillChunk(c, pat.block)
+			}
+
+			b.ReportMetric(float64(len(c)*int(unsafe.Sizeof(Empty))), "bytes/chunk")
+		})
+	}
+}
+
+func benchmarkLookup(b *testing.B, bs BlockStorage) {
+	var sink BlockType
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		sink += bs.Lookup(i&(ChunkSize-1), i>>5&(ChunkSize-1), i>>10&(ChunkSize-1))
+	}
+
+	_ = sink
+}
+
+func BenchmarkPaletteContainerLookup(b *testing.B) {
+	for _, pat := range chunkPatterns {
+		b.Run(pat.name, func(b *testing.B) {
+			p := NewPaletteContainer(Empty)
+			fillChunk(p, pat.block)
+
+			benchmarkLookup(b, p)
+		})
+	}
+}
+
+func BenchmarkBlockContainerLookup(b *testing.B) {
+	for _, pat := range chunkPatterns {
+		b.Run(pat.name, func(b *testing.B) {
+			c := make(BlockContainer, ChunkSizeCubed)
+			fillChunk(c, pat.block)
+
+			benchmarkLookup(b, c)
+		})
+	}
+}
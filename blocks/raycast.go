@@ -0,0 +1,93 @@
+package blocks
+
+import (
+	"math"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+// RayHit describes the first solid block found by a ray cast.
+type RayHit struct {
+	X, Y, Z int
+	Block   BlockType
+
+	// Normal is the unit normal of the face that the ray entered the block through. Adding it to the
+	// hit position gives the empty block in front of that face. It is zero when the ray starts inside
+	// the hit block.
+	Normal [3]int
+
+	Distance float64
+}
+
+// Raycast walks the blocks along the ray from origin in direction dir using a voxel DDA and returns the
+// first solid block within maxDist. The walk stops without a hit when it reaches a chunk that is
+// not loaded.
+func (cm *ChunkManager) Raycast(origin, dir mgl64.Vec3, maxDist float64) (RayHit, bool) {
+	if dir.Len() == 0 {
+		return RayHit{}, false
+	}
+	dir = dir.Normalize()
+
+	// Blocks are centered on their integer coordinates, so shift the origin to make each block span
+	// [n, n+1) along each axis.
+	o := origin.Add(mgl64.Vec3{BlockRenderSize, BlockRenderSize, BlockRenderSize})
+
+	var pos, step [3]int
+	var tMax, tDelta [3]float64
+
+	for i := 0; i < 3; i++ {
+		pos[i] = int(math.Floor(o[i]))
+
+		switch {
+		case dir[i] > 0:
+			step[i] = 1
+			tMax[i] = (float64(pos[i]) + 1 - o[i]) / dir[i]
+			tDelta[i] = 1 / dir[i]
+		case dir[i] < 0:
+			step[i] = -1
+			tMax[i] = (o[i] - float64(pos[i])) / -dir[i]
+			tDelta[i] = -1 / dir[i]
+		default:
+			tMax[i] = math.Inf(1)
+			tDelta[i] = math.Inf(1)
+		}
+	}
+
+	var normal [3]int
+	t := 0.0
+
+	for t <= maxDist {
+		bt, ok := cm.BlockAt(pos[0], pos[1], pos[2])
+		if !ok {
+			return RayHit{}, false
+		}
+
+		if bt.Definition().Solid {
+			return RayHit{
+				X:        pos[0],
+				Y:        pos[1],
+				Z:        pos[2],
+				Block:    bt,
+				Normal:   normal,
+				Distance: t,
+			}, true
+		}
+
+		axis := 0
+		if tMax[1] < tMax[axis] {
+			axis = 1
+		}
+		if tMax[2] < tMax[axis] {
+			axis = 2
+		}
+
+		t = tMax[axis]
+		pos[axis] += step[axis]
+		tMax[axis] += tDelta[axis]
+
+		normal = [3]int{}
+		normal[axis] = -step[axis]
+	}
+
+	return RayHit{}, false
+}
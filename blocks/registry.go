@@ -0,0 +1,399 @@
+package blocks
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+// Face identifies one of the six faces of a block.
+type Face int
+
+const (
+	FaceLeft Face = iota
+	FaceRight
+	FaceBottom
+	FaceTop
+	FaceBack
+	FaceFront
+)
+
+var faceNames = map[string]Face{
+	"left":   FaceLeft,
+	"right":  FaceRight,
+	"bottom": FaceBottom,
+	"top":    FaceTop,
+	"back":   FaceBack,
+	"front":  FaceFront,
+}
+
+// CollisionShape is the box, in block space from 0 to 1 along each axis, that entities collide with. A
+// zero value shape has no collision.
+type CollisionShape struct {
+	Min, Max mgl64.Vec3
+}
+
+var (
+	NoCollision   = CollisionShape{}
+	FullCollision = CollisionShape{Max: mgl64.Vec3{1, 1, 1}}
+)
+
+func (s CollisionShape) Empty() bool {
+	return s.Max.X() <= s.Min.X() || s.Max.Y() <= s.Min.Y() || s.Max.Z() <= s.Min.Z()
+}
+
+// BlockDefinition describes how a type of block looks and behaves.
+type BlockDefinition struct {
+	Name string
+
+	// Solid blocks stop entities and ray casts.
+	Solid bool
+
+	// Transparent blocks let light through and do not hide the faces of the blocks next to them.
+	Transparent bool
+
+	// Invisible blocks produce no geometry.
+	Invisible bool
+
+	// Translucency is how much of what is behind a transparent block shows through it, from 0 for
+	// fully opaque faces to 1 for completely clear ones.
+	Translucency float64
+
+	// LightEmission is the torchlight level, from 0 to MaxLightLevel, given off by the block.
+	LightEmission uint8
+
+	// FluidLevel is non-zero for fluids and says how full the block is, up to MaxFluidLevel for a
+	// source block.
+	FluidLevel uint8
+
+	Colors    [6]mgl64.Vec3
+	Textures  [6]string
+	Collision CollisionShape
+}
+
+// Registry maps each BlockType to its definition. Lookups are lock free so that the meshers and
+// lighting can consult it for every block; registration is comparatively slow.
+type Registry struct {
+	mu     sync.Mutex
+	defs   atomic.Value // []BlockDefinition indexed by BlockType
+	byName map[string]BlockType
+}
+
+// unknownBlock is returned for block types that have not been registered. It is bright magenta so that
+// missing definitions stand out.
+var unknownBlock = BlockDefinition{
+	Name:      "unknown",
+	Solid:     true,
+	Colors:    uniformColors(mgl64.Vec3{1, 0, 1}),
+	Collision: FullCollision,
+}
+
+// NewRegistry creates a registry containing only the Empty block.
+func NewRegistry() *Registry {
+	r := &Registry{
+		byName: make(map[string]BlockType),
+	}
+
+	r.defs.Store([]BlockDefinition{})
+	r.MustRegister(BlockDefinition{
+		Name:        "air",
+		Transparent: true,
+		Invisible:   true,
+		Collision:   NoCollision,
+	})
+
+	return r
+}
+
+// Register adds a block definition and returns the BlockType assigned to it. Names must be unique.
+func (r *Registry) Register(def BlockDefinition) (BlockType, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if def.Name == "" {
+		return Empty, fmt.Errorf("blocks: block definition must have a name")
+	}
+
+	if _, ok := r.byName[def.Name]; ok {
+		return Empty, fmt.Errorf("blocks: block %q is already registered", def.Name)
+	}
+
+	if def.LightEmission > MaxLightLevel {
+		return Empty, fmt.Errorf("blocks: block %q emits light %d above the maximum of %d", def.Name, def.LightEmission, MaxLightLevel)
+	}
+
+	old := r.defs.Load().([]BlockDefinition)
+	defs := make([]BlockDefinition, len(old), len(old)+1)
+	copy(defs, old)
+
+	bt := BlockType(len(defs))
+	r.defs.Store(append(defs, def))
+	r.byName[def.Name] = bt
+
+	return bt, nil
+}
+
+// MustRegister is like Register but panics if the definition cannot be registered.
+func (r *Registry) MustRegister(def BlockDefinition) BlockType {
+	bt, err := r.Register(def)
+	if err != nil {
+		panic(err)
+	}
+
+	return bt
+}
+
+// Get returns the definition for the block type. Unregistered types get a solid magenta definition.
+func (r *Registry) Get(bt BlockType) *BlockDefinition {
+	defs := r.defs.Load().([]BlockDefinition)
+	if bt < 0 || int(bt) >= len(defs) {
+		return &unknownBlock
+	}
+
+	return &defs[bt]
+}
+
+// Len returns the number of registered block types. Types run from Empty up to Len()-1.
+func (r *Registry) Len() int {
+	return len(r.defs.Load().([]BlockDefinition))
+}
+
+// Lookup returns the block type registered under the given name.
+func (r *Registry) Lookup(name string) (BlockType, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	bt, ok := r.byName[name]
+	return bt, ok
+}
+
+type jsonFace struct {
+	Color   *mgl64.Vec3 `json:"color"`
+	Texture string      `json:"texture"`
+}
+
+type jsonBlock struct {
+	Name         string              `json:"name"`
+	Solid        bool                `json:"solid"`
+	Transparent  bool                `json:"transparent"`
+	Invisible    bool                `json:"invisible"`
+	Light        uint8               `json:"light"`
+	Translucency float64             `json:"translucency"`
+	Color        mgl64.Vec3          `json:"color"`
+	Texture      string              `json:"texture"`
+	Faces        map[string]jsonFace `json:"faces"`
+	Collision    json.RawMessage     `json:"collision"`
+}
+
+// Load registers the blocks described by a JSON document of the form:
+//
+//	{"blocks": [{
+//		"name": "log", "solid": true, "transparent": false, "translucency": 0, "light": 0,
+//		"color": [0.4, 0.26, 0.13], "texture": "log_side.png",
+//		"faces": {"top": {"color": [0.6, 0.5, 0.3], "texture": "log_top.png"}},
+//		"collision": "full"
+//	}]}
+//
+// The color and texture apply to every face unless overridden in faces. Collision is "full", "none"
+// or a box given as [[minX, minY, minZ], [maxX, maxY, maxZ]], and defaults to "full" for solid blocks.
+func (r *Registry) Load(rd io.Reader) error {
+	var doc struct {
+		Blocks []jsonBlock `json:"blocks"`
+	}
+
+	if err := json.NewDecoder(rd).Decode(&doc); err != nil {
+		return fmt.Errorf("blocks: decoding registry: %v", err)
+	}
+
+	for _, b := range doc.Blocks {
+		def, err := b.definition()
+		if err != nil {
+			return err
+		}
+
+		if _, err := r.Register(def); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// LoadFile registers the blocks described by the JSON file at path. See Load for the format.
+func (r *Registry) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return r.Load(f)
+}
+
+func (b jsonBlock) definition() (BlockDefinition, error) {
+	def := BlockDefinition{
+		Name:          b.Name,
+		Solid:         b.Solid,
+		Transparent:   b.Transparent,
+		Invisible:     b.Invisible,
+		Translucency:  b.Translucency,
+		LightEmission: b.Light,
+		Colors:        uniformColors(b.Color),
+	}
+
+	for i := range def.Textures {
+		def.Textures[i] = b.Texture
+	}
+
+	for name, f := range b.Faces {
+		face, ok := faceNames[name]
+		if !ok {
+			return def, fmt.Errorf("blocks: block %q has unknown face %q", b.Name, name)
+		}
+
+		if f.Color != nil {
+			def.Colors[face] = *f.Color
+		}
+
+		if f.Texture != "" {
+			def.Textures[face] = f.Texture
+		}
+	}
+
+	var shape interface{}
+	if len(b.Collision) > 0 {
+		if err := json.Unmarshal(b.Collision, &shape); err != nil {
+			return def, fmt.Errorf("blocks: block %q has invalid collision: %v", b.Name, err)
+		}
+	}
+
+	switch s := shape.(type) {
+	case nil:
+		if b.Solid {
+			def.Collision = FullCollision
+		}
+	case string:
+		switch s {
+		case "full":
+			def.Collision = FullCollision
+		case "none":
+			def.Collision = NoCollision
+		default:
+			return def, fmt.Errorf("blocks: block %q has unknown collision %q", b.Name, s)
+		}
+	default:
+		var box [2]mgl64.Vec3
+		if err := json.Unmarshal(b.Collision, &box); err != nil {
+			return def, fmt.Errorf("blocks: block %q has invalid collision box: %v", b.Name, err)
+		}
+
+		def.Collision = CollisionShape{Min: box[0], Max: box[1]}
+	}
+
+	return def, nil
+}
+
+func uniformColors(c mgl64.Vec3) [6]mgl64.Vec3 {
+	return [6]mgl64.Vec3{c, c, c, c, c, c}
+}
+
+// DefaultRegistry is the registry consulted by the meshers, lighting and ray casts.
+var DefaultRegistry = NewRegistry()
+
+// The blocks used by the built in terrain generation. Further blocks can be added to DefaultRegistry
+// with Register or Load.
+var (
+	Grass = DefaultRegistry.MustRegister(BlockDefinition{
+		Name:      "grass",
+		Solid:     true,
+		Colors:    uniformColors(mgl64.Vec3{0.094, 0.568, 0.109}),
+		Collision: FullCollision,
+	})
+	Stone = DefaultRegistry.MustRegister(BlockDefinition{
+		Name:      "stone",
+		Solid:     true,
+		Colors:    uniformColors(mgl64.Vec3{0.423, 0.478, 0.537}),
+		Collision: FullCollision,
+	})
+	Glass = DefaultRegistry.MustRegister(BlockDefinition{
+		Name:         "glass",
+		Solid:        true,
+		Transparent:  true,
+		Translucency: 0.7,
+		Colors:       uniformColors(mgl64.Vec3{0.78, 0.9, 0.95}),
+		Collision:    FullCollision,
+	})
+	Dirt = DefaultRegistry.MustRegister(BlockDefinition{
+		Name:      "dirt",
+		Solid:     true,
+		Colors:    uniformColors(mgl64.Vec3{0.45, 0.31, 0.18}),
+		Collision: FullCollision,
+	})
+	Sand = DefaultRegistry.MustRegister(BlockDefinition{
+		Name:      "sand",
+		Solid:     true,
+		Colors:    uniformColors(mgl64.Vec3{0.86, 0.8, 0.55}),
+		Collision: FullCollision,
+	})
+	Snow = DefaultRegistry.MustRegister(BlockDefinition{
+		Name:      "snow",
+		Solid:     true,
+		Colors:    uniformColors(mgl64.Vec3{0.94, 0.96, 0.98}),
+		Collision: FullCollision,
+	})
+	Water = DefaultRegistry.MustRegister(waterDefinition("water", MaxFluidLevel))
+)
+
+// flowingWater holds the block type of water at each fluid level, with Empty at level 0 and Water at
+// MaxFluidLevel.
+var flowingWater = registerFlowingWater()
+
+// Blocks used by the default decorations.
+var (
+	Log = DefaultRegistry.MustRegister(BlockDefinition{
+		Name:      "log",
+		Solid:     true,
+		Colors:    uniformColors(mgl64.Vec3{0.4, 0.26, 0.13}),
+		Collision: FullCollision,
+	})
+	Leaves = DefaultRegistry.MustRegister(BlockDefinition{
+		Name:         "leaves",
+		Solid:        true,
+		Transparent:  true,
+		Translucency: 0,
+		Colors:       uniformColors(mgl64.Vec3{0.13, 0.42, 0.12}),
+		Collision:    FullCollision,
+	})
+)
+
+func waterDefinition(name string, level uint8) BlockDefinition {
+	return BlockDefinition{
+		Name:         name,
+		Transparent:  true,
+		Translucency: 0.6,
+		FluidLevel:   level,
+		Colors:       uniformColors(mgl64.Vec3{0.16, 0.38, 0.82}),
+		Collision:    NoCollision,
+	}
+}
+
+func registerFlowingWater() [MaxFluidLevel + 1]BlockType {
+	var levels [MaxFluidLevel + 1]BlockType
+
+	for l := uint8(1); l < MaxFluidLevel; l++ {
+		levels[l] = DefaultRegistry.MustRegister(waterDefinition(fmt.Sprintf("flowing water %d", l), l))
+	}
+	levels[MaxFluidLevel] = Water
+
+	return levels
+}
+
+// Definition returns the definition of the block type from DefaultRegistry.
+func (bt BlockType) Definition() *BlockDefinition {
+	return DefaultRegistry.Get(bt)
+}
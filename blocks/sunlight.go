@@ -0,0 +1,136 @@
+package blocks
+
+import (
+	"container/list"
+)
+
+// seedSunlight lights the chunk with sunlight falling from above. A column is lit at full strength
+// from the top of the chunk down to its first solid block, and spread sideways from there, when the
+// chunk above is not loaded, which is treated as open sky, or when full sunlight reaches the bottom
+// of the chunk above.
+func seedSunlight(c, above *Chunk) {
+	queue := list.New()
+
+	for x := 0; x < ChunkSize; x++ {
+		for z := 0; z < ChunkSize; z++ {
+			if above != nil && above.lightMap.Sunlight(x, 0, z) != MaxLightLevel {
+				continue
+			}
+
+			n := LightNode{Chunk: c, X: x, Y: ChunkSize - 1, Z: z}
+			if !n.block().Definition().Transparent {
+				continue
+			}
+
+			n.setLight(sunlight, MaxLightLevel)
+			queue.PushBack(n)
+		}
+	}
+
+	// The chunk has no neighbour links yet so the spread cannot leave it.
+	propagateLight(sunlight, queue, map[*Chunk]bool{c: true})
+}
+
+// relightBorder spreads torch and sunlight across the shared face of two chunks that have just been
+// linked as neighbours. It returns every chunk whose light map changed.
+func relightBorder(a, b *Chunk) []*Chunk {
+	changed := make(map[*Chunk]bool)
+
+	lo, hi := a, b
+	ac, bc := a.Coord(), b.Coord()
+
+	axis := 0
+	switch {
+	case ac.Y != bc.Y:
+		axis = 1
+		if ac.Y > bc.Y {
+			lo, hi = b, a
+		}
+	case ac.Z != bc.Z:
+		axis = 2
+		if ac.Z > bc.Z {
+			lo, hi = b, a
+		}
+	default:
+		if ac.X > bc.X {
+			lo, hi = b, a
+		}
+	}
+
+	face := func(c *Chunk, d, i, j int) LightNode {
+		var p [3]int
+		p[axis], p[(axis+1)%3], p[(axis+2)%3] = d, i, j
+		return LightNode{Chunk: c, X: p[0], Y: p[1], Z: p[2]}
+	}
+
+	// A chunk is seeded as if it were under open sky when the chunk above it is not loaded. Now that
+	// the chunk above is here, remove any full sunlight that it does not actually let through.
+	if axis == 1 {
+		var unlit []LightNode
+
+		for i := 0; i < ChunkSize; i++ {
+			for j := 0; j < ChunkSize; j++ {
+				top := face(lo, ChunkSize-1, i, j)
+				if top.light(sunlight) == MaxLightLevel && face(hi, 0, i, j).light(sunlight) != MaxLightLevel {
+					unlit = append(unlit, top)
+				}
+			}
+		}
+
+		removeLight(sunlight, changed, unlit...)
+	}
+
+	for _, ch := range []lightChannel{torchlight, sunlight} {
+		queue := list.New()
+
+		for i := 0; i < ChunkSize; i++ {
+			for j := 0; j < ChunkSize; j++ {
+				queue.PushBack(face(lo, ChunkSize-1, i, j))
+				queue.PushBack(face(hi, 0, i, j))
+			}
+		}
+
+		propagateLight(ch, queue, changed)
+	}
+
+	return chunkList(changed)
+}
+
+// relightBlock updates the torch and sunlight around a block that has just been changed from old.
+// Light held by a block that no longer lets light through is removed, while light from the surrounding
+// blocks is spread into a block that now does. Light given off by either block is removed or spread
+// as needed. It returns every chunk whose light map changed.
+func relightBlock(n LightNode, old BlockType) []*Chunk {
+	changed := make(map[*Chunk]bool)
+
+	def := n.block().Definition()
+	oldDef := old.Definition()
+
+	for _, ch := range []lightChannel{torchlight, sunlight} {
+		if !def.Transparent || (ch == torchlight && oldDef.LightEmission > 0) {
+			removeLight(ch, changed, n)
+
+			if !def.Transparent {
+				continue
+			}
+		}
+
+		queue := list.New()
+
+		for _, o := range faceOffsets {
+			if nb, ok := n.neighbour(o[0], o[1], o[2]); ok {
+				queue.PushBack(nb)
+			}
+		}
+
+		propagateLight(ch, queue, changed)
+	}
+
+	if def.LightEmission > 0 {
+		for _, c := range placeTorchlight(n, def.LightEmission) {
+			changed[c] = true
+		}
+	}
+
+	return chunkList(changed)
+}
@@ -0,0 +1,120 @@
+package blocks
+
+import (
+	"math"
+
+	"github.com/nickbryan/voxel/noise"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+// TerrainGenerator fills the blocks of a newly created chunk whose minimum corner is at pos in world
+// space. Chunks are generated from several goroutines so implementations must be safe for concurrent use.
+type TerrainGenerator interface {
+	Generate(blocks BlockStorage, pos mgl64.Vec3)
+}
+
+// HeightmapParams tunes a HeightmapGenerator. Terrain height is taken from low rolling noise, raised
+// to the height of a second, hillier noise wherever a selector noise is negative.
+type HeightmapParams struct {
+	Seed int64
+
+	LowOctaves, HighOctaves, SelectorOctaves int
+
+	// Scale multiplies world positions before the low and high noise is sampled.
+	Scale float64
+
+	// The low and high noise is divided by its divisor and then moved up or down by its offset.
+	LowDivisor, LowOffset   float64
+	HighDivisor, HighOffset float64
+
+	// HeightScale is applied to every height and DepthScale is applied again to heights below zero,
+	// flattening out the valleys.
+	HeightScale, DepthScale float64
+
+	// Empty space below SeaLevel is filled with Water.
+	SeaLevel float64
+
+	Block, Water BlockType
+}
+
+// DefaultHeightmapParams returns the parameters for the standard grassy terrain.
+func DefaultHeightmapParams(seed int64) HeightmapParams {
+	return HeightmapParams{
+		Seed:            seed,
+		LowOctaves:      8,
+		HighOctaves:     8,
+		SelectorOctaves: 6,
+		Scale:           1.3,
+		LowDivisor:      6,
+		LowOffset:       -4,
+		HighDivisor:     5,
+		HighOffset:      6,
+		HeightScale:     0.5,
+		DepthScale:      0.8,
+		SeaLevel:        -2,
+		Block:           Grass,
+		Water:           Water,
+	}
+}
+
+// HeightmapGenerator fills every column of a chunk up to a height taken from 2D noise.
+type HeightmapGenerator struct {
+	HeightmapParams
+
+	low, high *noise.CombinedNoise
+	selector  *noise.OctaveNoise
+}
+
+func NewHeightmapGenerator(p HeightmapParams) *HeightmapGenerator {
+	return &HeightmapGenerator{
+		HeightmapParams: p,
+		low:             noise.NewCombined(noise.NewOctave(p.Seed, p.LowOctaves), noise.NewOctave(p.Seed+1, p.LowOctaves)),
+		high:            noise.NewCombined(noise.NewOctave(p.Seed+2, p.HighOctaves), noise.NewOctave(p.Seed+3, p.HighOctaves)),
+		selector:        noise.NewOctave(p.Seed+4, p.SelectorOctaves),
+	}
+}
+
+func (g *HeightmapGenerator) Generate(blocks BlockStorage, pos mgl64.Vec3) {
+	for x := 0.0; x < ChunkSize; x++ {
+		xPos := pos.X() + x
+
+		for z := 0.0; z < ChunkSize; z++ {
+			zPos := pos.Z() + z
+
+			height := g.Height(xPos, zPos)
+
+			for y := 0.0; y < ChunkSize; y++ {
+				yPos := pos.Y() + y
+				switch {
+				case yPos < height:
+					blocks.Set(int(x), int(y), int(z), g.Block)
+				case yPos < g.SeaLevel:
+					blocks.Set(int(x), int(y), int(z), g.Water)
+				default:
+					blocks.Set(int(x), int(y), int(z), Empty)
+				}
+			}
+		}
+	}
+}
+
+// Height returns the height of the terrain at the world position x, z. The selector is sampled at the
+// world position like the rest of the noise, so the choice of high terrain does not repeat every chunk.
+func (g *HeightmapGenerator) Height(x, z float64) float64 {
+	hLow := g.low.Compute(x*g.Scale, z*g.Scale)/g.LowDivisor + g.LowOffset
+	height := hLow
+
+	if g.selector.Eval2(x, z) <= 0 {
+		hHigh := g.high.Compute(x*g.Scale, z*g.Scale)/g.HighDivisor + g.HighOffset
+		height = math.Max(hLow, hHigh)
+	}
+
+	height *= g.HeightScale
+	if height < 0 {
+		height *= g.DepthScale
+	}
+
+	// TODO: cap this somehow
+	return height
+}
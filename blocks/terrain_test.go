@@ -0,0 +1,54 @@
+package blocks
+
+import (
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+// columnTops returns the height of the highest solid block in each column of the chunk at pos.
+func columnTops(g TerrainGenerator, pos mgl64.Vec3) [ChunkSize][ChunkSize]int {
+	c := NewPaletteContainer(Empty)
+	g.Generate(c, pos)
+
+	var tops [ChunkSize][ChunkSize]int
+	for x := 0; x < ChunkSize; x++ {
+		for z := 0; z < ChunkSize; z++ {
+			tops[x][z] = -1
+			for y := 0; y < ChunkSize; y++ {
+				if c.Lookup(x, y, z) != Empty {
+					tops[x][z] = y
+				}
+			}
+		}
+	}
+
+	return tops
+}
+
+func TestHeightmapSelectorDoesNotRepeat(t *testing.T) {
+	// Flatten the low and high noise so that the height only shows where the selector picks the high
+	// terrain: a column is 11 blocks tall there and empty elsewhere.
+	p := DefaultHeightmapParams(3)
+	p.LowDivisor, p.LowOffset = 1e9, -0.5
+	p.HighDivisor, p.HighOffset = 1e9, 10.5
+	p.HeightScale, p.DepthScale = 1, 1
+	p.SeaLevel = -1
+	g := NewHeightmapGenerator(p)
+
+	tests := []struct {
+		name string
+		a, b mgl64.Vec3
+	}{
+		{"along X", mgl64.Vec3{0, 0, 0}, mgl64.Vec3{ChunkSize, 0, 0}},
+		{"along Z", mgl64.Vec3{0, 0, 0}, mgl64.Vec3{0, 0, ChunkSize}},
+		{"along -X", mgl64.Vec3{0, 0, 0}, mgl64.Vec3{-ChunkSize, 0, 0}},
+		{"away from the origin", mgl64.Vec3{5 * ChunkSize, 0, -7 * ChunkSize}, mgl64.Vec3{6 * ChunkSize, 0, -7 * ChunkSize}},
+	}
+
+	for _, tt := range tests {
+		if columnTops(g, tt.a) == columnTops(g, tt.b) {
+			t.Errorf("%s: neighbouring chunks have identical columns", tt.name)
+		}
+	}
+}
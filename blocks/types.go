@@ -6,11 +6,9 @@ import (
 
 type BlockType int
 
-const (
-	Empty BlockType = iota
-	Grass
-	Stone
-)
+// Empty is the block type of air. It is always the first type in a Registry; every other type is
+// assigned when its definition is registered.
+const Empty BlockType = 0
 
 const (
 	BlockRenderSize float64 = 0.5
@@ -27,10 +25,53 @@ type MeshRenderer interface {
 	AddVertex(p mgl64.Vec3) uint32
 	AddTriangle(v1, v2, v3 uint32)
 	SetColor(c mgl64.Vec3)
+	SetAlpha(a float64)
+	SetLight(torchlight, sunlight uint8)
 	Finish()
 	TearDown()
 }
 
+// ChunkMeshes are the meshes that a chunk's geometry is written to. Faces of transparent blocks go to
+// Transparent so that they can be drawn after, and blended over, the Opaque faces.
+type ChunkMeshes struct {
+	Opaque, Transparent MeshRenderer
+}
+
+// For returns the mesh that faces of the block type are written to.
+func (m ChunkMeshes) For(bt BlockType) MeshRenderer {
+	if bt.Definition().Transparent {
+		return m.Transparent
+	}
+
+	return m.Opaque
+}
+
+func (m ChunkMeshes) Finish() {
+	m.Opaque.Finish()
+	m.Transparent.Finish()
+}
+
+func (m ChunkMeshes) TearDown() {
+	m.Opaque.TearDown()
+	m.Transparent.TearDown()
+}
+
+// Mesher builds the geometry for a chunk's blocks and writes it to ChunkMeshes. Implementations must
+// not hold on to any of the given data once BuildMesh returns.
+type Mesher interface {
+	BuildMesh(meshes ChunkMeshes, blocks BlockStorage, lightMap LightMapContainer, offset mgl64.Vec3, n Neighbours)
+}
+
+// MesherFactory creates the Mesher used by a chunk.
+type MesherFactory func() Mesher
+
+// Neighbours holds the chunks adjacent to a chunk. A nil entry means that neighbour is not loaded.
+type Neighbours struct {
+	XMinus, XPlus *Chunk
+	YMinus, YPlus *Chunk
+	ZMinus, ZPlus *Chunk
+}
+
 type LightNode struct {
 	Chunk   *Chunk
 	X, Y, Z int
@@ -0,0 +1,125 @@
+package blocks
+
+// BlockAt returns the block at the given world block coordinates. The second return value reports
+// whether the chunk containing the block is loaded; when it is not Empty is returned.
+func (cm *ChunkManager) BlockAt(x, y, z int) (BlockType, bool) {
+	coord, lx, ly, lz := worldToLocal(x, y, z)
+
+	ch, ok := cm.chunks.Lookup(coord)
+	if !ok {
+		return Empty, false
+	}
+
+	cm.worldMu.RLock()
+	defer cm.worldMu.RUnlock()
+
+	return ch.blocks.Lookup(lx, ly, lz), true
+}
+
+// LightAt returns the torch and sunlight levels at the given world block coordinates. The last return
+// value reports whether the chunk containing the block is loaded.
+func (cm *ChunkManager) LightAt(x, y, z int) (torchlight, sunlight uint8, ok bool) {
+	coord, lx, ly, lz := worldToLocal(x, y, z)
+
+	ch, ok := cm.chunks.Lookup(coord)
+	if !ok {
+		return 0, 0, false
+	}
+
+	cm.worldMu.RLock()
+	defer cm.worldMu.RUnlock()
+
+	return ch.lightMap.Torchlight(lx, ly, lz), ch.lightMap.Sunlight(lx, ly, lz), true
+}
+
+// SetBlock sets the block at the given world block coordinates, updates the light around it and queues
+// the owning chunk, along with any neighbour sharing a face with the block or whose light changed, for
+// a mesh rebuild. Fluids around the block are checked on the next fluid tick. It reports whether the
+// chunk containing the block is loaded; nothing is changed when it is not.
+func (cm *ChunkManager) SetBlock(x, y, z int, bt BlockType) bool {
+	coord, lx, ly, lz := worldToLocal(x, y, z)
+
+	ch, ok := cm.chunks.Lookup(coord)
+	if !ok {
+		return false
+	}
+
+	cm.worldMu.Lock()
+	old := ch.blocks.Lookup(lx, ly, lz)
+	ch.blocks.Set(lx, ly, lz, bt)
+	changed := relightBlock(LightNode{Chunk: ch, X: lx, Y: ly, Z: lz}, old)
+	cm.worldMu.Unlock()
+
+	ch.markDirty()
+	cm.meshWorkers.Enqueue(ch)
+
+	for _, c := range changed {
+		c.markDirty()
+		cm.meshWorkers.Enqueue(c)
+	}
+
+	for _, o := range borderOffsets(lx, ly, lz) {
+		if nch, ok := cm.chunks.Lookup(coord.Offset(o[0], o[1], o[2])); ok {
+			cm.meshWorkers.Enqueue(nch)
+		}
+	}
+
+	cm.activateFluids(x, y, z)
+
+	return true
+}
+
+// worldToLocal splits world block coordinates into the coordinate of the chunk containing the block
+// and the block's position within that chunk. Division floors so negative coordinates resolve to the
+// chunk below rather than towards zero.
+func worldToLocal(x, y, z int) (ChunkCoord, int, int, int) {
+	cx, lx := floorDivMod(x, ChunkSize)
+	cy, ly := floorDivMod(y, ChunkSize)
+	cz, lz := floorDivMod(z, ChunkSize)
+
+	return ChunkCoord{int32(cx), int32(cy), int32(cz)}, lx, ly, lz
+}
+
+func floorDivMod(a, b int) (int, int) {
+	q, r := a/b, a%b
+	if r < 0 {
+		q--
+		r += b
+	}
+
+	return q, r
+}
+
+// borderOffsets returns the chunk offsets of the neighbours that share a face with the block at the
+// given local position.
+func borderOffsets(x, y, z int) [][3]int32 {
+	var offsets [][3]int32
+
+	if x == 0 {
+		offsets = append(offsets, [3]int32{-1, 0, 0})
+	} else if x == ChunkSize-1 {
+		offsets = append(offsets, [3]int32{1, 0, 0})
+	}
+
+	if y == 0 {
+		offsets = append(offsets, [3]int32{0, -1, 0})
+	} else if y == ChunkSize-1 {
+		offsets = append(offsets, [3]int32{0, 1, 0})
+	}
+
+	if z == 0 {
+		offsets = append(offsets, [3]int32{0, 0, -1})
+	} else if z == ChunkSize-1 {
+		offsets = append(offsets, [3]int32{0, 0, 1})
+	}
+
+	return offsets
+}
+
+// ChunksAround returns the loaded chunks within radius chunks, along every axis, of the chunk holding
+// the block at the given world block coordinates.
+func (cm *ChunkManager) ChunksAround(x, y, z int, radius int32) []*Chunk {
+	coord, _, _, _ := worldToLocal(x, y, z)
+
+	return cm.chunks.Neighbourhood(coord, radius)
+}
@@ -0,0 +1,221 @@
+// Command worldgen generates a box of chunks without opening a window, to try out terrain generation on
+// machines with no GPU. It can save the chunks in the game's region format and draw top down height and
+// colour maps of them.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nickbryan/voxel/blocks"
+	"github.com/nickbryan/voxel/storage"
+)
+
+var (
+	seed      = flag.Int64("seed", 420, "seed used to generate the world")
+	minFlag   = flag.String("min", "-4,-2,-4", "lowest chunk coordinate to generate, as x,y,z")
+	maxFlag   = flag.String("max", "3,1,3", "highest chunk coordinate to generate, as x,y,z")
+	generator = flag.String("generator", "density", "terrain generator to use: density or heightmap")
+	decorate  = flag.Bool("decorate", true, "place trees and other features")
+	out       = flag.String("out", "", "directory to write region files to")
+	heightmap = flag.String("heightmap", "", "file to write a greyscale PNG height map to")
+	colormap  = flag.String("colormap", "", "file to write a PNG map of the colour of the top blocks to")
+	verbose   = flag.Bool("v", false, "print how long each chunk took")
+)
+
+func main() {
+	flag.Parse()
+
+	min, err := parseCoord(*minFlag)
+	if err != nil {
+		log.Fatalln("Invalid -min: ", err)
+	}
+
+	max, err := parseCoord(*maxFlag)
+	if err != nil {
+		log.Fatalln("Invalid -max: ", err)
+	}
+
+	if min.X > max.X || min.Y > max.Y || min.Z > max.Z {
+		log.Fatalln("-min must not be above -max")
+	}
+
+	var g blocks.TerrainGenerator
+	switch *generator {
+	case "density":
+		g = blocks.NewDensityGenerator(blocks.DefaultDensityParams(*seed))
+	case "heightmap":
+		g = blocks.NewHeightmapGenerator(blocks.DefaultHeightmapParams(*seed))
+	default:
+		log.Fatalf("Unknown generator %q", *generator)
+	}
+
+	var d *blocks.Decorator
+	if *decorate {
+		d = blocks.NewDecorator(*seed, blocks.DefaultDecorations()...)
+	}
+
+	var total, slowest time.Duration
+	count := 0
+
+	start := time.Now()
+	box := blocks.GenerateChunkBox(min, max, g, d, func(c blocks.ChunkCoord, took time.Duration) {
+		if *verbose {
+			fmt.Printf("chunk %d,%d,%d: %v\n", c.X, c.Y, c.Z, took)
+		}
+
+		total += took
+		count++
+		if took > slowest {
+			slowest = took
+		}
+	})
+
+	fmt.Printf("Generated %d chunks in %v (%v per chunk, slowest %v), lit in %v\n",
+		count, total, total/time.Duration(count), slowest, time.Since(start)-total)
+
+	if *out != "" {
+		store, err := storage.NewRegionStore(*out)
+		if err != nil {
+			log.Fatalln("Failed to open region store: ", err)
+		}
+
+		if err := box.Save(store); err != nil {
+			log.Fatalln("Failed to save chunks: ", err)
+		}
+	}
+
+	if *heightmap != "" {
+		if err := writePNG(*heightmap, drawHeightmap(box)); err != nil {
+			log.Fatalln("Failed to write height map: ", err)
+		}
+	}
+
+	if *colormap != "" {
+		if err := writePNG(*colormap, drawColormap(box)); err != nil {
+			log.Fatalln("Failed to write colour map: ", err)
+		}
+	}
+}
+
+// parseCoord parses a chunk coordinate written as x,y,z.
+func parseCoord(s string) (blocks.ChunkCoord, error) {
+	fields := strings.Split(s, ",")
+	if len(fields) != 3 {
+		return blocks.ChunkCoord{}, fmt.Errorf("%q has %d fields, want x,y,z", s, len(fields))
+	}
+
+	var xyz [3]int32
+	for i, f := range fields {
+		n, err := strconv.ParseInt(f, 10, 32)
+		if err != nil {
+			return blocks.ChunkCoord{}, err
+		}
+
+		xyz[i] = int32(n)
+	}
+
+	return blocks.ChunkCoord{X: xyz[0], Y: xyz[1], Z: xyz[2]}, nil
+}
+
+// topBlock returns the highest block in the column at x, z for which include is true, along with its
+// height.
+func topBlock(box *blocks.ChunkBox, x, z int, include func(bt blocks.BlockType) bool) (blocks.BlockType, int, bool) {
+	for y := (int(box.Max.Y)+1)*blocks.ChunkSize - 1; y >= int(box.Min.Y)*blocks.ChunkSize; y-- {
+		if bt, _ := box.BlockAt(x, y, z); include(bt) {
+			return bt, y, true
+		}
+	}
+
+	return blocks.Empty, 0, false
+}
+
+// eachColumn calls fn for every block column in the box with its world coordinates and the pixel it
+// maps to, and returns the bounds of the image.
+func eachColumn(box *blocks.ChunkBox, fn func(x, z, px, py int)) image.Rectangle {
+	minX, minZ := int(box.Min.X)*blocks.ChunkSize, int(box.Min.Z)*blocks.ChunkSize
+	w := int(box.Max.X-box.Min.X+1) * blocks.ChunkSize
+	h := int(box.Max.Z-box.Min.Z+1) * blocks.ChunkSize
+
+	if fn != nil {
+		for px := 0; px < w; px++ {
+			for py := 0; py < h; py++ {
+				fn(minX+px, minZ+py, px, py)
+			}
+		}
+	}
+
+	return image.Rect(0, 0, w, h)
+}
+
+// heightFraction maps a height in the box to between 0 at the bottom and 1 at the top.
+func heightFraction(box *blocks.ChunkBox, y int) float64 {
+	bottom := int(box.Min.Y) * blocks.ChunkSize
+	top := (int(box.Max.Y) + 1) * blocks.ChunkSize
+
+	return float64(y-bottom) / float64(top-bottom)
+}
+
+func drawHeightmap(box *blocks.ChunkBox) image.Image {
+	img := image.NewGray(eachColumn(box, nil))
+
+	eachColumn(box, func(x, z, px, py int) {
+		_, y, ok := topBlock(box, x, z, func(bt blocks.BlockType) bool {
+			return bt != blocks.Empty && bt.Definition().FluidLevel == 0
+		})
+
+		if ok {
+			img.SetGray(px, py, color.Gray{Y: uint8(255 * heightFraction(box, y))})
+		}
+	})
+
+	return img
+}
+
+func drawColormap(box *blocks.ChunkBox) image.Image {
+	img := image.NewRGBA(eachColumn(box, nil))
+
+	eachColumn(box, func(x, z, px, py int) {
+		bt, y, ok := topBlock(box, x, z, func(bt blocks.BlockType) bool {
+			return !bt.Definition().Invisible
+		})
+
+		if !ok {
+			return
+		}
+
+		// Shade by height so that hills stand out from the ground around them.
+		c := bt.Definition().Colors[blocks.FaceTop].Mul(0.6 + 0.4*heightFraction(box, y))
+
+		img.SetRGBA(px, py, color.RGBA{
+			R: uint8(255 * c.X()),
+			G: uint8(255 * c.Y()),
+			B: uint8(255 * c.Z()),
+			A: 255,
+		})
+	})
+
+	return img
+}
+
+func writePNG(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/nickbryan/voxel/blocks"
+)
+
+func TestParseCoord(t *testing.T) {
+	tests := []struct {
+		in   string
+		want blocks.ChunkCoord
+		ok   bool
+	}{
+		{"1,2,3", blocks.ChunkCoord{X: 1, Y: 2, Z: 3}, true},
+		{"-4,-2,-4", blocks.ChunkCoord{X: -4, Y: -2, Z: -4}, true},
+		{"1,2,3junk", blocks.ChunkCoord{}, false},
+		{"1,2,3,4", blocks.ChunkCoord{}, false},
+		{"1,2", blocks.ChunkCoord{}, false},
+		{"1, 2,3", blocks.ChunkCoord{}, false},
+		{"1,,3", blocks.ChunkCoord{}, false},
+		{"1,2,3000000000", blocks.ChunkCoord{}, false},
+		{"", blocks.ChunkCoord{}, false},
+	}
+
+	for _, tt := range tests {
+		got, err := parseCoord(tt.in)
+		if (err == nil) != tt.ok {
+			t.Errorf("parseCoord(%q) error = %v, want ok %v", tt.in, err, tt.ok)
+			continue
+		}
+
+		if got != tt.want {
+			t.Errorf("parseCoord(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package engine
+
+import (
+	"math"
+)
+
+// DefaultDayLength is the number of seconds it takes for a full day to pass.
+const DefaultDayLength = 600.0
+
+// minDaylight keeps the world from going completely dark at midnight.
+const minDaylight = 0.15
+
+// Clock tracks the time of day in the world. It only moves forward when advanced by the engine's
+// update loop, so it stays in step with the fixed update rate rather than the frame rate.
+type Clock struct {
+	DayLength float64
+
+	seconds float64
+	paused  bool
+}
+
+func NewClock(dayLength float64) *Clock {
+	return &Clock{
+		DayLength: dayLength,
+		seconds:   dayLength / 2,
+	}
+}
+
+// Advance moves the clock forward by dt seconds unless it is paused.
+func (c *Clock) Advance(dt float64) {
+	if c.paused {
+		return
+	}
+
+	c.seconds = math.Mod(c.seconds+dt, c.DayLength)
+}
+
+// TimeOfDay returns how far through the day the clock is, from 0 at midnight through 0.5 at midday to
+// just below 1.
+func (c *Clock) TimeOfDay() float64 {
+	return c.seconds / c.DayLength
+}
+
+// SetTimeOfDay jumps the clock to the given point in the day, using the same scale as TimeOfDay.
+func (c *Clock) SetTimeOfDay(t float64) {
+	t = math.Mod(t, 1)
+	if t < 0 {
+		t++
+	}
+
+	c.seconds = t * c.DayLength
+}
+
+// Daylight returns how strongly the sun lights the world, from minDaylight at midnight to 1 at midday.
+func (c *Clock) Daylight() float64 {
+	sun := 0.5 - 0.5*math.Cos(2*math.Pi*c.TimeOfDay())
+
+	return minDaylight + (1-minDaylight)*sun
+}
+
+func (c *Clock) Pause() {
+	c.paused = true
+}
+
+func (c *Clock) Resume() {
+	c.paused = false
+}
+
+func (c *Clock) Paused() bool {
+	return c.paused
+}
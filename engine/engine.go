@@ -3,24 +3,46 @@ package engine
 import (
 	"fmt"
 	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
 	"sync"
+	"time"
 
 	"github.com/nickbryan/voxel/entity"
 
 	"github.com/nickbryan/voxel/blocks"
 
+	"github.com/nickbryan/voxel/export"
+
 	"github.com/nickbryan/voxel/input"
 
 	"github.com/nickbryan/voxel/renderer"
 
+	"github.com/nickbryan/voxel/storage"
+
 	"github.com/faiface/mainthread"
 	"github.com/go-gl/gl/v4.1-core/gl"
 	"github.com/go-gl/glfw/v3.2/glfw"
 )
 
+// worldDir is where the region files of each world are kept, in a directory named after its seed.
+const worldDir = "world"
+
+// Pressing X exports the chunks within exportRadius chunks of the player to exportDir.
+const (
+	exportDir    = "exports"
+	exportRadius = 2
+)
+
+// DefaultSeed is the seed of the world generated when none is given.
+const DefaultSeed int64 = 420
+
 type Engine struct {
 	win                 *glfw.Window
 	WinWidth, WinHeight uint
+	Seed                int64
 	closed              bool
 	running             bool
 
@@ -30,6 +52,10 @@ type Engine struct {
 	camera       *entity.Camera
 	inputManager *input.Input
 	player       *entity.Player
+	clock        *Clock
+
+	// fluidTime accumulates update time until the next fluid tick.
+	fluidTime float64
 
 	chunkManager *blocks.ChunkManager
 }
@@ -38,9 +64,16 @@ func New(winWidth, winHeight uint) *Engine {
 	return &Engine{
 		WinWidth:  winWidth,
 		WinHeight: winHeight,
+		Seed:      DefaultSeed,
+		clock:     NewClock(DefaultDayLength),
 	}
 }
 
+// Clock returns the world clock, which can be paused or set to a time of day for debugging.
+func (e *Engine) Clock() *Clock {
+	return e.clock
+}
+
 func (e *Engine) Run() {
 	if e.running {
 		return
@@ -165,16 +198,69 @@ func (e *Engine) setup() {
 			e.player.Climb(-0.05)
 			fmt.Println(e.player.Pos())
 		}))
+		e.inputManager.AddKeyCommands(glfw.KeyP, input.Press, input.KeyCommandFunc(func() {
+			if e.clock.Paused() {
+				e.clock.Resume()
+			} else {
+				e.clock.Pause()
+			}
+			fmt.Println("Clock paused: ", e.clock.Paused())
+		}))
+		e.inputManager.AddKeyCommands(glfw.KeyT, input.Press, input.KeyCommandFunc(func() {
+			// Jump forward to the next quarter of the day: midnight, dawn, midday or dusk.
+			e.clock.SetTimeOfDay(math.Floor(e.clock.TimeOfDay()*4+1) / 4)
+			fmt.Println("Time of day: ", e.clock.TimeOfDay())
+		}))
+		e.inputManager.AddKeyCommands(glfw.KeyX, input.Press, input.KeyCommandFunc(func() {
+			go e.exportChunks()
+		}))
 		e.inputManager.AddMouseMoveCommands(input.MouseMoveCommandFunc(func(offsetX, offsetY float64) {
 			e.player.Look(float32(offsetX), float32(offsetY))
 		}))
 	})
 
-	e.chunkManager = blocks.NewChunkManager(e.renderer, e.player)
+	opts := []blocks.Option{
+		blocks.WithGenerator(blocks.NewDensityGenerator(blocks.DefaultDensityParams(e.Seed))),
+		blocks.WithDecorator(blocks.NewDecorator(e.Seed, blocks.DefaultDecorations()...)),
+	}
+
+	store, err := storage.NewRegionStore(filepath.Join(worldDir, strconv.FormatInt(e.Seed, 10)))
+	if err != nil {
+		log.Println("Chunks will not be saved: ", err)
+	} else {
+		opts = append(opts, blocks.WithStore(store))
+	}
+
+	e.chunkManager = blocks.NewChunkManager(renderBackend{e.renderer}, e.player, opts...)
 
 }
 
+// exportChunks writes the chunks around the player to OBJ and glTF files in exportDir.
+func (e *Engine) exportChunks() {
+	if err := os.MkdirAll(exportDir, 0755); err != nil {
+		log.Println("Failed to export chunks: ", err)
+		return
+	}
+
+	pos := e.player.Pos()
+	chunks := e.chunkManager.ChunksAround(int(math.Round(float64(pos.X()))), int(math.Round(float64(pos.Y()))), int(math.Round(float64(pos.Z()))), exportRadius)
+
+	base := filepath.Join(exportDir, time.Now().Format("20060102-150405"))
+	if err := export.WriteFiles(base, chunks); err != nil {
+		log.Println("Failed to export chunks: ", err)
+		return
+	}
+
+	fmt.Printf("Exported %d chunks to %s.obj and %s.glb\n", len(chunks), base, base)
+}
+
 func (e *Engine) tearDown() {
+	e.chunkManager.Close()
+
+	if err := e.chunkManager.Save(); err != nil {
+		log.Println("Failed to save chunks: ", err)
+	}
+
 	e.renderer.Teardown()
 
 	mainthread.Call(func() {
@@ -183,15 +269,26 @@ func (e *Engine) tearDown() {
 }
 
 func (e *Engine) update(dt float64) {
+	e.clock.Advance(dt)
+
+	e.fluidTime += dt
+	for e.fluidTime >= blocks.FluidTickInterval {
+		e.fluidTime -= blocks.FluidTickInterval
+		e.chunkManager.TickFluids()
+	}
+
 	e.inputManager.Update()
 	e.camera.Update()
 }
 
 func (e *Engine) render(alpha float64) {
 	mainthread.Call(func() {
-		gl.ClearColor(0.57, 0.71, 0.77, 1)
+		daylight := e.clock.Daylight()
+
+		gl.ClearColor(float32(0.57*daylight), float32(0.71*daylight), float32(0.77*daylight), 1)
 		gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
 
+		e.renderer.SetDaylight(daylight)
 		e.renderer.Draw(e.camera)
 
 		e.win.SwapBuffers()
@@ -0,0 +1,19 @@
+package engine
+
+import (
+	"github.com/nickbryan/voxel/blocks"
+	"github.com/nickbryan/voxel/renderer"
+)
+
+// renderBackend lets the chunk manager create meshes with the renderer.
+type renderBackend struct {
+	r *renderer.Renderer
+}
+
+func (b renderBackend) CreateMesh() blocks.MeshRenderer {
+	return b.r.CreateMesh()
+}
+
+func (b renderBackend) CreateTransparentMesh() blocks.MeshRenderer {
+	return b.r.CreateTransparentMesh()
+}
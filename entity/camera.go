@@ -47,6 +47,10 @@ func (c *Camera) CreateViewMatrix() mgl32.Mat4 {
 	return mgl32.LookAtV(c.Movable.pos, c.Movable.pos.Add(c.Movable.front), c.Movable.up)
 }
 
+func (c *Camera) Pos() mgl32.Vec3 {
+	return c.Movable.pos
+}
+
 func (c *Camera) Attach(a attachable) {
 	c.attachedEntity = a
 }
@@ -0,0 +1,35 @@
+package export
+
+import (
+	"io"
+	"os"
+
+	"github.com/nickbryan/voxel/blocks"
+	"github.com/nickbryan/voxel/blocks/meshrec"
+)
+
+// WriteFiles meshes the chunks and writes them to base.obj and base.glb.
+func WriteFiles(base string, chunks []*blocks.Chunk) error {
+	opaque, transparent := Chunks(chunks)
+	meshes := map[string]*meshrec.Recorder{"opaque": opaque, "transparent": transparent}
+
+	if err := writeFile(base+".obj", meshes, WriteOBJ); err != nil {
+		return err
+	}
+
+	return writeFile(base+".glb", meshes, WriteGLB)
+}
+
+func writeFile(path string, meshes map[string]*meshrec.Recorder, write func(w io.Writer, meshes map[string]*meshrec.Recorder) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := write(f, meshes); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
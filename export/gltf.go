@@ -0,0 +1,223 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/nickbryan/voxel/blocks/meshrec"
+)
+
+// glTF constants used by the exporter.
+const (
+	glbMagic      = 0x46546C67 // "glTF"
+	glbVersion    = 2
+	glbChunkJSON  = 0x4E4F534A // "JSON"
+	glbChunkBIN   = 0x004E4942 // "BIN\0"
+	componentF32  = 5126
+	componentU32  = 5125
+	targetArray   = 34962
+	targetElement = 34963
+)
+
+type gltfDoc struct {
+	Asset       map[string]string `json:"asset"`
+	Scene       int               `json:"scene"`
+	Scenes      []gltfScene       `json:"scenes"`
+	Nodes       []gltfNode        `json:"nodes,omitempty"`
+	Meshes      []gltfMesh        `json:"meshes,omitempty"`
+	Materials   []gltfMaterial    `json:"materials,omitempty"`
+	Accessors   []gltfAccessor    `json:"accessors,omitempty"`
+	BufferViews []gltfBufferView  `json:"bufferViews,omitempty"`
+	Buffers     []gltfBuffer      `json:"buffers,omitempty"`
+}
+
+type gltfScene struct {
+	Nodes []int `json:"nodes"`
+}
+
+type gltfNode struct {
+	Name string `json:"name"`
+	Mesh int    `json:"mesh"`
+}
+
+type gltfMesh struct {
+	Name       string          `json:"name"`
+	Primitives []gltfPrimitive `json:"primitives"`
+}
+
+type gltfPrimitive struct {
+	Attributes map[string]int `json:"attributes"`
+	Indices    int            `json:"indices"`
+	Material   int            `json:"material"`
+}
+
+type gltfMaterial struct {
+	Name      string `json:"name"`
+	AlphaMode string `json:"alphaMode"`
+	PBR       struct {
+		BaseColorFactor [4]float64 `json:"baseColorFactor"`
+		MetallicFactor  float64    `json:"metallicFactor"`
+		RoughnessFactor float64    `json:"roughnessFactor"`
+	} `json:"pbrMetallicRoughness"`
+}
+
+type gltfAccessor struct {
+	BufferView    int       `json:"bufferView"`
+	ComponentType int       `json:"componentType"`
+	Count         int       `json:"count"`
+	Type          string    `json:"type"`
+	Min           []float64 `json:"min,omitempty"`
+	Max           []float64 `json:"max,omitempty"`
+}
+
+type gltfBufferView struct {
+	Buffer     int `json:"buffer"`
+	ByteOffset int `json:"byteOffset"`
+	ByteLength int `json:"byteLength"`
+	Target     int `json:"target"`
+}
+
+type gltfBuffer struct {
+	ByteLength int `json:"byteLength"`
+}
+
+// WriteGLB writes the meshes as nodes of a binary glTF 2.0 file. Meshes whose vertices are not all
+// fully opaque are given a blended material.
+func WriteGLB(w io.Writer, meshes map[string]*meshrec.Recorder) error {
+	doc := gltfDoc{
+		Asset:  map[string]string{"version": "2.0", "generator": "voxel"},
+		Scenes: []gltfScene{{Nodes: []int{}}},
+	}
+
+	var bin bytes.Buffer
+
+	view := func(data interface{}, target int) int {
+		offset := bin.Len()
+		binary.Write(&bin, binary.LittleEndian, data)
+
+		doc.BufferViews = append(doc.BufferViews, gltfBufferView{
+			ByteOffset: offset,
+			ByteLength: bin.Len() - offset,
+			Target:     target,
+		})
+
+		return len(doc.BufferViews) - 1
+	}
+
+	accessor := func(a gltfAccessor) int {
+		doc.Accessors = append(doc.Accessors, a)
+		return len(doc.Accessors) - 1
+	}
+
+	for _, name := range sortedNames(meshes) {
+		m := meshes[name]
+		if m.Empty() {
+			continue
+		}
+
+		positions := make([]float32, 0, len(m.Vertices)*3)
+		colors := make([]float32, 0, len(m.Vertices)*4)
+		min := []float64{math.Inf(1), math.Inf(1), math.Inf(1)}
+		max := []float64{math.Inf(-1), math.Inf(-1), math.Inf(-1)}
+		opaque := true
+
+		for _, v := range m.Vertices {
+			for i := 0; i < 3; i++ {
+				positions = append(positions, float32(v.Pos[i]))
+				min[i] = math.Min(min[i], float64(float32(v.Pos[i])))
+				max[i] = math.Max(max[i], float64(float32(v.Pos[i])))
+			}
+
+			c := v.Color
+			colors = append(colors, float32(c[0]), float32(c[1]), float32(c[2]), float32(v.Alpha))
+			opaque = opaque && v.Alpha >= 1
+		}
+
+		prim := gltfPrimitive{
+			Attributes: map[string]int{
+				"POSITION": accessor(gltfAccessor{
+					BufferView:    view(positions, targetArray),
+					ComponentType: componentF32,
+					Count:         len(m.Vertices),
+					Type:          "VEC3",
+					Min:           min,
+					Max:           max,
+				}),
+				"COLOR_0": accessor(gltfAccessor{
+					BufferView:    view(colors, targetArray),
+					ComponentType: componentF32,
+					Count:         len(m.Vertices),
+					Type:          "VEC4",
+				}),
+			},
+			Indices: accessor(gltfAccessor{
+				BufferView:    view(m.Triangles, targetElement),
+				ComponentType: componentU32,
+				Count:         len(m.Triangles) * 3,
+				Type:          "SCALAR",
+			}),
+			Material: len(doc.Materials),
+		}
+
+		mat := gltfMaterial{Name: name, AlphaMode: "OPAQUE"}
+		if !opaque {
+			mat.AlphaMode = "BLEND"
+		}
+		mat.PBR.BaseColorFactor = [4]float64{1, 1, 1, 1}
+		mat.PBR.RoughnessFactor = 1
+
+		doc.Materials = append(doc.Materials, mat)
+		doc.Meshes = append(doc.Meshes, gltfMesh{Name: name, Primitives: []gltfPrimitive{prim}})
+		doc.Nodes = append(doc.Nodes, gltfNode{Name: name, Mesh: len(doc.Meshes) - 1})
+		doc.Scenes[0].Nodes = append(doc.Scenes[0].Nodes, len(doc.Nodes)-1)
+	}
+
+	if bin.Len() > 0 {
+		doc.Buffers = []gltfBuffer{{ByteLength: bin.Len()}}
+	}
+
+	js, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	// Both chunks must be padded to four bytes, the JSON with spaces and the binary data with zeros.
+	for len(js)%4 != 0 {
+		js = append(js, ' ')
+	}
+	for bin.Len()%4 != 0 {
+		bin.WriteByte(0)
+	}
+
+	total := 12 + 8 + len(js)
+	if bin.Len() > 0 {
+		total += 8 + bin.Len()
+	}
+
+	var out bytes.Buffer
+	binary.Write(&out, binary.LittleEndian, [3]uint32{glbMagic, glbVersion, uint32(total)})
+	binary.Write(&out, binary.LittleEndian, [2]uint32{uint32(len(js)), glbChunkJSON})
+	out.Write(js)
+
+	if bin.Len() > 0 {
+		binary.Write(&out, binary.LittleEndian, [2]uint32{uint32(bin.Len()), glbChunkBIN})
+		out.Write(bin.Bytes())
+	}
+
+	_, err = w.Write(out.Bytes())
+	return err
+}
+
+func sortedNames(meshes map[string]*meshrec.Recorder) []string {
+	names := make([]string, 0, len(meshes))
+	for name := range meshes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,18 @@
+// Package export writes chunk geometry to files that can be opened in modelling tools.
+package export
+
+import (
+	"github.com/nickbryan/voxel/blocks"
+	"github.com/nickbryan/voxel/blocks/meshrec"
+)
+
+// Chunks meshes the chunks with their own meshers into a single opaque and a single transparent mesh.
+func Chunks(chunks []*blocks.Chunk) (opaque, transparent *meshrec.Recorder) {
+	opaque, transparent = meshrec.NewRecorder(), meshrec.NewRecorder()
+
+	for _, ch := range chunks {
+		ch.BuildMesh(blocks.ChunkMeshes{Opaque: opaque, Transparent: transparent})
+	}
+
+	return opaque, transparent
+}
@@ -0,0 +1,42 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"github.com/nickbryan/voxel/blocks/meshrec"
+)
+
+// WriteOBJ writes the meshes as objects in a Wavefront OBJ file. Vertex colours follow each vertex
+// position, an extension understood by Blender and MeshLab; alpha cannot be stored and is dropped.
+func WriteOBJ(w io.Writer, meshes map[string]*meshrec.Recorder) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintln(bw, "# Exported by voxel")
+
+	// OBJ indexes vertices from 1 across the whole file.
+	base := uint32(1)
+
+	for _, name := range sortedNames(meshes) {
+		m := meshes[name]
+		if m.Empty() {
+			continue
+		}
+
+		fmt.Fprintf(bw, "o %s\n", name)
+
+		for _, v := range m.Vertices {
+			p, c := v.Pos, v.Color
+			fmt.Fprintf(bw, "v %g %g %g %.4f %.4f %.4f\n", p.X(), p.Y(), p.Z(), c.X(), c.Y(), c.Z())
+		}
+
+		for _, t := range m.Triangles {
+			fmt.Fprintf(bw, "f %d %d %d\n", base+t[0], base+t[1], base+t[2])
+		}
+
+		base += uint32(len(m.Vertices))
+	}
+
+	return bw.Flush()
+}
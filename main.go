@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"log"
 	"net/http"
 
@@ -16,12 +17,17 @@ const (
 	WindowHeight uint = 900
 )
 
+var seed = flag.Int64("seed", engine.DefaultSeed, "seed used to generate the world")
+
 func run() {
 	e := engine.New(WindowWidth, WindowHeight)
+	e.Seed = *seed
 	e.Run()
 }
 
 func main() {
+	flag.Parse()
+
 	go func() {
 		log.Fatal(http.ListenAndServe(`localhost:4200`, nil))
 	}()
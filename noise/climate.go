@@ -0,0 +1,30 @@
+package noise
+
+// Climate samples smoothly varying temperature and humidity maps, used to decide which biome each part
+// of the world belongs to. Both are normalised to [-1, 1].
+type Climate struct {
+	temperature, humidity *OctaveNoise
+}
+
+// NewClimate creates climate maps from seed whose features are roughly 1/frequency blocks across.
+func NewClimate(seed int64, frequency float64) *Climate {
+	return &Climate{
+		temperature: newClimateNoise(octaveSeed(seed, -2), frequency),
+		humidity:    newClimateNoise(octaveSeed(seed, -3), frequency),
+	}
+}
+
+func newClimateNoise(seed int64, frequency float64) *OctaveNoise {
+	n := NewOctave(seed, 3)
+	n.Frequency = frequency
+	n.Lacunarity = 2
+	n.Persistence = 0.5
+	n.Normalized = true
+
+	return n
+}
+
+// Sample returns the temperature and humidity at x, z.
+func (c *Climate) Sample(x, z float64) (temperature, humidity float64) {
+	return c.temperature.Eval2(x, z), c.humidity.Eval2(x, z)
+}
@@ -9,6 +9,6 @@ func NewCombined(n1, n2 *OctaveNoise) *CombinedNoise {
 }
 
 func (c *CombinedNoise) Compute(x, y float64) float64 {
-	offset := c.n2.Compute(x, y)
-	return c.n1.Compute(x+offset, y)
+	offset := c.n2.Eval2(x, y)
+	return c.n1.Eval2(x+offset, y)
 }
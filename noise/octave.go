@@ -1,31 +1,102 @@
 package noise
 
-import simplex "github.com/ojrac/opensimplex-go"
+import (
+	"math"
 
+	simplex "github.com/ojrac/opensimplex-go"
+)
+
+// The largest magnitude returned by the 2D and 3D simplex noise, used to normalise the output. They
+// were found by refining the peaks of densely sampled noise across many seeds, and rounded up.
+const (
+	simplexMax2 = 0.865921
+	simplexMax3 = 0.987147
+)
+
+// OctaveNoise sums several octaves of simplex noise. The first octave is sampled at Frequency and
+// scaled by Amplitude, and each following octave multiplies the frequency by Lacunarity and the
+// amplitude by Persistence.
 type OctaveNoise struct {
+	Frequency   float64
+	Amplitude   float64
+	Lacunarity  float64
+	Persistence float64
+
+	// Normalized scales the sum by the total amplitude of the octaves so that the output is in [-1, 1].
+	Normalized bool
+
 	baseNoise []simplex.Noise
 }
 
-func NewOctave(octaves int) *OctaveNoise {
+// NewOctave creates noise with the given number of octaves, each seeded differently from seed. It
+// halves the frequency and doubles the amplitude with each octave, which the terrain generators were
+// tuned against; set the fields to change this.
+func NewOctave(seed int64, octaves int) *OctaveNoise {
 	o := &OctaveNoise{
-		baseNoise: make([]simplex.Noise, octaves),
+		Frequency:   1,
+		Amplitude:   1,
+		Lacunarity:  0.5,
+		Persistence: 2,
+		baseNoise:   make([]simplex.Noise, octaves),
 	}
 
 	for i := 0; i < octaves; i++ {
-		o.baseNoise[i] = simplex.New(420)
+		o.baseNoise[i] = simplex.New(octaveSeed(seed, i))
 	}
 
 	return o
 }
 
-func (o *OctaveNoise) Compute(x, y float64) float64 {
-	amp, freq, sum := 1.0, 1.0, 0.0
+// Eval2 samples the noise in two dimensions.
+func (o *OctaveNoise) Eval2(x, y float64) float64 {
+	amp, freq, sum := o.Amplitude, o.Frequency, 0.0
 
 	for i := 0; i < len(o.baseNoise); i++ {
 		sum += o.baseNoise[i].Eval2(x*freq, y*freq) * amp
-		amp *= 2.0
-		freq *= 0.5
+		amp *= o.Persistence
+		freq *= o.Lacunarity
 	}
 
-	return sum
+	return o.normalize(sum, simplexMax2)
+}
+
+// Eval3 samples the noise in three dimensions.
+func (o *OctaveNoise) Eval3(x, y, z float64) float64 {
+	amp, freq, sum := o.Amplitude, o.Frequency, 0.0
+
+	for i := 0; i < len(o.baseNoise); i++ {
+		sum += o.baseNoise[i].Eval3(x*freq, y*freq, z*freq) * amp
+		amp *= o.Persistence
+		freq *= o.Lacunarity
+	}
+
+	return o.normalize(sum, simplexMax3)
+}
+
+func (o *OctaveNoise) normalize(sum, max float64) float64 {
+	if !o.Normalized {
+		return sum
+	}
+
+	amp, total := o.Amplitude, 0.0
+	for range o.baseNoise {
+		total += math.Abs(amp)
+		amp *= o.Persistence
+	}
+
+	if total == 0 {
+		return 0
+	}
+
+	return sum / (total * max)
+}
+
+// octaveSeed derives the seed of an octave from the base seed by mixing in its index with the
+// SplitMix64 finaliser, so that neighbouring seeds and octaves give unrelated noise.
+func octaveSeed(seed int64, octave int) int64 {
+	z := uint64(seed) + uint64(octave+1)*0x9e3779b97f4a7c15
+	z = (z ^ z>>30) * 0xbf58476d1ce4e5b9
+	z = (z ^ z>>27) * 0x94d049bb133111eb
+
+	return int64(z ^ z>>31)
 }
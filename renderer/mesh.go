@@ -3,6 +3,7 @@ package renderer
 import (
 	"github.com/faiface/mainthread"
 	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
 	"github.com/go-gl/mathgl/mgl64"
 )
 
@@ -12,23 +13,37 @@ type Mesh struct {
 	indices       []uint32
 	vertexCount   uint32
 	activeColor   mgl64.Vec3
+	activeAlpha   float32
+	activeLight   [2]float32
 	active        bool
+	transparent   bool
+	min, max      mgl32.Vec3
+
+	// uploaded and indexCount are only touched on the main thread so that Draw can safely skip
+	// meshes that are still being built on another goroutine.
+	uploaded   bool
+	indexCount int32
+	center     mgl32.Vec3
 }
 
-func (m *Mesh) Setup() {
-	m.active = true
-
-	mainthread.Call(func() {
-		gl.GenVertexArrays(1, &m.vao)
-		gl.GenBuffers(1, &m.vbo)
-		gl.GenBuffers(1, &m.ebo)
-	})
+// setup creates the mesh's GL objects. It is deferred until the mesh is first uploaded so that meshes
+// can be built without a GL context, and empty meshes never need any. It must be called on the main
+// thread.
+func (m *Mesh) setup() {
+	gl.GenVertexArrays(1, &m.vao)
+	gl.GenBuffers(1, &m.vbo)
+	gl.GenBuffers(1, &m.ebo)
 }
 
 func (m *Mesh) TearDown() {
-	m.active = false
-
 	mainthread.Call(func() {
+		m.active = false
+		m.uploaded = false
+
+		if m.vao == 0 {
+			return
+		}
+
 		gl.DeleteVertexArrays(1, &m.vao)
 		gl.DeleteBuffers(1, &m.vbo)
 		gl.DeleteBuffers(1, &m.ebo)
@@ -41,6 +56,10 @@ func (m *Mesh) Finish() {
 	}
 
 	mainthread.Call(func() {
+		if m.vao == 0 {
+			m.setup()
+		}
+
 		gl.BindVertexArray(m.vao)
 
 		gl.BindBuffer(gl.ARRAY_BUFFER, m.vbo)
@@ -50,21 +69,43 @@ func (m *Mesh) Finish() {
 		gl.BufferData(gl.ELEMENT_ARRAY_BUFFER, len(m.indices)*4, gl.Ptr(m.indices), gl.STATIC_DRAW)
 
 		// Position
-		gl.VertexAttribPointer(0, 3, gl.FLOAT, false, 24, gl.PtrOffset(0))
+		gl.VertexAttribPointer(0, 3, gl.FLOAT, false, 36, gl.PtrOffset(0))
 		gl.EnableVertexAttribArray(0)
 
 		//Color
-		// TODO: 24 = 6 * sizeof(float) and 12 = 3 * sizeof(float)
-		gl.VertexAttribPointer(1, 3, gl.FLOAT, false, 24, gl.PtrOffset(12))
+		// TODO: 36 = 9 * sizeof(float) and 12 = 3 * sizeof(float)
+		gl.VertexAttribPointer(1, 4, gl.FLOAT, false, 36, gl.PtrOffset(12))
 		gl.EnableVertexAttribArray(1)
 
+		// Light
+		gl.VertexAttribPointer(2, 2, gl.FLOAT, false, 36, gl.PtrOffset(28))
+		gl.EnableVertexAttribArray(2)
+
 		// Ensure we unbind the VAO after so other VAO calls won't accidentally modify it.
 		gl.BindBuffer(gl.ARRAY_BUFFER, 0)
 		gl.BindVertexArray(0)
+
+		m.indexCount = int32(len(m.indices))
+		m.center = m.min.Add(m.max).Mul(0.5)
+		m.uploaded = true
 	})
 }
 
 func (m *Mesh) AddVertex(p mgl64.Vec3) uint32 {
+	v := mgl32.Vec3{float32(p.X()), float32(p.Y()), float32(p.Z())}
+	if m.vertexCount == 0 {
+		m.min, m.max = v, v
+	}
+
+	for i := 0; i < 3; i++ {
+		if v[i] < m.min[i] {
+			m.min[i] = v[i]
+		}
+		if v[i] > m.max[i] {
+			m.max[i] = v[i]
+		}
+	}
+
 	m.vertices = append(
 		m.vertices,
 		float32(p.X()),
@@ -73,6 +114,9 @@ func (m *Mesh) AddVertex(p mgl64.Vec3) uint32 {
 		float32(m.activeColor.X()),
 		float32(m.activeColor.Y()),
 		float32(m.activeColor.Z()),
+		m.activeAlpha,
+		m.activeLight[0],
+		m.activeLight[1],
 	)
 
 	vCount := m.vertexCount
@@ -88,3 +132,19 @@ func (m *Mesh) AddTriangle(v1, v2, v3 uint32) {
 func (m *Mesh) SetColor(c mgl64.Vec3) {
 	m.activeColor = c
 }
+
+// SetAlpha sets the opacity, from 0 to 1, used for the following vertices. It only has an effect on
+// transparent meshes.
+func (m *Mesh) SetAlpha(a float64) {
+	m.activeAlpha = float32(a)
+}
+
+// SetLight sets the torch and sunlight levels, from 0 to 15, used for the following vertices.
+func (m *Mesh) SetLight(torchlight, sunlight uint8) {
+	m.activeLight = [2]float32{float32(torchlight) / 15, float32(sunlight) / 15}
+}
+
+func (m *Mesh) draw() {
+	gl.BindVertexArray(m.vao)
+	gl.DrawElements(gl.TRIANGLES, m.indexCount, gl.UNSIGNED_INT, gl.PtrOffset(0))
+}
@@ -2,6 +2,8 @@ package renderer
 
 import (
 	"fmt"
+	"sort"
+	"sync"
 
 	"github.com/nickbryan/voxel/entity"
 
@@ -14,9 +16,11 @@ const (
 	vertexShader = `
 #version 410 core
 layout (location = 0) in vec3 aPos;
-layout (location = 1) in vec3 aColor;
+layout (location = 1) in vec4 aColor;
+layout (location = 2) in vec2 aLight;
 
-out vec3 clr; 
+out vec4 clr;
+out vec2 light;
 
 uniform mat4 model;
 uniform mat4 view;
@@ -26,28 +30,40 @@ void main()
 {
     gl_Position = projection * view * model * vec4(aPos, 1);
 	clr = aColor;
+	light = aLight;
 }
 ` + "\x00"
 	fragmentShader = `
 #version 410 core
 out vec4 FragColor;
-in vec3 clr;
+in vec4 clr;
+in vec2 light;
+
+uniform float daylight;
 
 void main()
 {
-    FragColor = vec4(clr, 1.0f);
-} 
+    // Sunlight is scaled by the time of day while torchlight stays constant.
+    float level = max(light.x, light.y * daylight) * 15.0;
+    float lightColor = pow(level / 16.0, 1.4) + 0.86;
+
+    FragColor = vec4(clr.rgb * lightColor, clr.a);
+}
 ` + "\x00"
 )
 
 type Renderer struct {
 	vertexShader, fragmentShader uint32
 	shaderProgram                uint32
-	meshes                       []*Mesh
+	daylight                     float32
+
+	// meshesMu guards meshes as meshes are created from the chunk mesh workers.
+	meshesMu sync.Mutex
+	meshes   []*Mesh
 }
 
 func New() *Renderer {
-	r := &Renderer{}
+	r := &Renderer{daylight: 1}
 
 	r.Setup() // TODO: move this to a once callback somewhere to ensure initialisation (update loop maybe?)
 
@@ -66,17 +82,35 @@ func (r *Renderer) Setup() {
 }
 
 func (r *Renderer) Teardown() {
+	r.meshesMu.Lock()
+	defer r.meshesMu.Unlock()
+
 	for _, m := range r.meshes {
 		m.TearDown()
 	}
 }
 
+// SetDaylight sets how strongly sunlight lights the scene, from 0 at night to 1 at midday.
+func (r *Renderer) SetDaylight(d float64) {
+	r.daylight = float32(d)
+}
+
 func (r *Renderer) CreateMesh() *Mesh {
-	m := &Mesh{}
+	return r.createMesh(false)
+}
+
+// CreateTransparentMesh creates a mesh that is drawn with alpha blending after all of the opaque
+// meshes, furthest from the camera first.
+func (r *Renderer) CreateTransparentMesh() *Mesh {
+	return r.createMesh(true)
+}
 
-	m.Setup()
+func (r *Renderer) createMesh(transparent bool) *Mesh {
+	m := &Mesh{activeAlpha: 1, transparent: transparent, active: true}
 
+	r.meshesMu.Lock()
 	r.meshes = append(r.meshes, m)
+	r.meshesMu.Unlock()
 
 	return m
 }
@@ -105,18 +139,54 @@ func (r *Renderer) Draw(c *entity.Camera) {
 	}
 	gl.UniformMatrix4fv(location, 1, false, &view[0])
 
+	location = gl.GetUniformLocation(r.shaderProgram, gl.Str("daylight\x00"))
+	if location == -1 {
+		panic("Could not get daylight location")
+	}
+	gl.Uniform1f(location, r.daylight)
+
+	r.meshesMu.Lock()
+	defer r.meshesMu.Unlock()
+
 	for i := 0; i < len(r.meshes); i++ {
 		if r.meshes[i].active == false {
 			r.meshes = append(r.meshes[:i], r.meshes[i+1:]...)
 		}
 	}
 
+	var transparent []*Mesh
+
 	for _, m := range r.meshes {
-		if m.vertexCount > 0 {
-			gl.BindVertexArray(m.vao)
-			gl.DrawElements(gl.TRIANGLES, int32(len(m.indices)), gl.UNSIGNED_INT, gl.PtrOffset(0))
+		if !m.uploaded {
+			continue
 		}
+
+		if m.transparent {
+			transparent = append(transparent, m)
+			continue
+		}
+
+		m.draw()
 	}
+
+	// Blend the transparent meshes back to front over the opaque scene. Depth writes are disabled so
+	// that transparent faces do not hide each other, while the depth test still hides them behind
+	// opaque geometry.
+	camPos := c.Pos()
+	sort.Slice(transparent, func(i, j int) bool {
+		return transparent[i].center.Sub(camPos).Len() > transparent[j].center.Sub(camPos).Len()
+	})
+
+	gl.Enable(gl.BLEND)
+	gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
+	gl.DepthMask(false)
+
+	for _, m := range transparent {
+		m.draw()
+	}
+
+	gl.DepthMask(true)
+	gl.Disable(gl.BLEND)
 }
 
 func (r *Renderer) createShaders() {
@@ -0,0 +1,253 @@
+package schematic
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/nickbryan/voxel/blocks"
+	"github.com/nickbryan/voxel/storage"
+)
+
+// FormatVersion is written to every schematic file and bumped whenever the layout changes.
+const FormatVersion uint16 = 1
+
+// MaxSize and MaxVolume bound the schematics that can be saved and loaded, so that a corrupt or crafted
+// file cannot make Decode allocate more than a few hundred megabytes.
+const (
+	MaxSize   = 1024
+	MaxVolume = 1 << 24
+)
+
+const (
+	magic = "VXSC"
+
+	flagLight = 1 << 0
+
+	maxNameLen = 256
+)
+
+// Encode writes the schematic to w. Blocks are stored by name rather than BlockType so that the file
+// can be loaded by a game whose registry assigns different types. The layout is:
+//
+//	magic "VXSC", version uint16, flags uint8, then as uvarints the size along X, Y and Z,
+//	the number of palette names followed by each name as a length and bytes,
+//	the palette index of every block in runs, and the light bytes in runs when flagLight is set.
+//
+// Runs are the uvarint run length and value pairs written by storage.WriteRuns.
+func Encode(w io.Writer, s *Schematic) error {
+	if err := checkSize(s.Size); err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+
+	var flags uint8
+	if s.Light != nil {
+		flags |= flagLight
+	}
+
+	bw.WriteString(magic)
+	binary.Write(bw, binary.LittleEndian, FormatVersion)
+	bw.WriteByte(flags)
+
+	buf := make([]byte, binary.MaxVarintLen64)
+	putUvarint := func(v uint64) {
+		bw.Write(buf[:binary.PutUvarint(buf, v)])
+	}
+
+	for _, n := range s.Size {
+		putUvarint(uint64(n))
+	}
+
+	palette := make(map[blocks.BlockType]uint64)
+	var names []string
+
+	values := make([]uint64, len(s.Blocks))
+	for i, bt := range s.Blocks {
+		p, ok := palette[bt]
+		if !ok {
+			p = uint64(len(names))
+			palette[bt] = p
+			names = append(names, bt.Definition().Name)
+		}
+
+		values[i] = p
+	}
+
+	putUvarint(uint64(len(names)))
+	for _, name := range names {
+		putUvarint(uint64(len(name)))
+		bw.WriteString(name)
+	}
+
+	if err := storage.WriteRuns(bw, values); err != nil {
+		return err
+	}
+
+	if s.Light != nil {
+		for i, l := range s.Light {
+			values[i] = uint64(l)
+		}
+
+		if err := storage.WriteRuns(bw, values); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+// Decode reads a schematic written by Encode. Block names are resolved against blocks.DefaultRegistry.
+func Decode(r io.Reader) (*Schematic, error) {
+	br := bufio.NewReader(r)
+
+	var header struct {
+		Magic   [4]byte
+		Version uint16
+		Flags   uint8
+	}
+	if err := binary.Read(br, binary.LittleEndian, &header); err != nil {
+		return nil, fmt.Errorf("schematic: reading header: %v", err)
+	}
+
+	if string(header.Magic[:]) != magic {
+		return nil, fmt.Errorf("schematic: not a schematic file")
+	}
+
+	if header.Version != FormatVersion {
+		return nil, fmt.Errorf("schematic: unsupported format version %d", header.Version)
+	}
+
+	var size [3]int
+	for i := range size {
+		n, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, fmt.Errorf("schematic: reading size: %v", err)
+		}
+
+		if n > MaxSize {
+			return nil, fmt.Errorf("schematic: size %d exceeds the maximum of %d", n, MaxSize)
+		}
+
+		size[i] = int(n)
+	}
+
+	if err := checkSize(size); err != nil {
+		return nil, err
+	}
+
+	count, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, fmt.Errorf("schematic: reading palette: %v", err)
+	}
+
+	// Every palette entry is used by at least one block.
+	if count > uint64(size[0]*size[1]*size[2]) {
+		return nil, fmt.Errorf("schematic: palette of %d entries is larger than the schematic", count)
+	}
+
+	palette := make([]blocks.BlockType, 0, count)
+	for i := uint64(0); i < count; i++ {
+		l, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, fmt.Errorf("schematic: reading palette: %v", err)
+		}
+
+		if l > maxNameLen {
+			return nil, fmt.Errorf("schematic: block name of %d bytes is too long", l)
+		}
+
+		name := make([]byte, l)
+		if _, err := io.ReadFull(br, name); err != nil {
+			return nil, fmt.Errorf("schematic: reading palette: %v", err)
+		}
+
+		bt, ok := blocks.DefaultRegistry.Lookup(string(name))
+		if !ok {
+			return nil, fmt.Errorf("schematic: unknown block %q", name)
+		}
+
+		palette = append(palette, bt)
+	}
+
+	s := New(size[0], size[1], size[2])
+
+	var (
+		outOfRange    bool
+		outOfRangeIdx uint64
+	)
+	err = storage.ReadRuns(br, len(s.Blocks), func(i int, v uint64) {
+		if v < uint64(len(palette)) {
+			s.Blocks[i] = palette[v]
+		} else {
+			outOfRange, outOfRangeIdx = true, v
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("schematic: decoding blocks: %v", err)
+	}
+
+	if outOfRange {
+		return nil, fmt.Errorf("schematic: palette index %d out of range", outOfRangeIdx)
+	}
+
+	if header.Flags&flagLight != 0 {
+		s.Light = make([]byte, len(s.Blocks))
+
+		err := storage.ReadRuns(br, len(s.Light), func(i int, v uint64) {
+			s.Light[i] = byte(v)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("schematic: decoding light: %v", err)
+		}
+	}
+
+	return s, nil
+}
+
+// checkSize reports an error if a schematic of the given size is too large to save or load.
+func checkSize(size [3]int) error {
+	volume := 1
+	for _, n := range size {
+		if n < 0 || n > MaxSize {
+			return fmt.Errorf("schematic: size %d exceeds the maximum of %d", n, MaxSize)
+		}
+
+		volume *= n
+	}
+
+	if volume > MaxVolume {
+		return fmt.Errorf("schematic: %d blocks exceeds the maximum of %d", volume, MaxVolume)
+	}
+
+	return nil
+}
+
+// Save writes the schematic to the file at path, replacing it if it exists.
+func (s *Schematic) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := Encode(f, s); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// Load reads the schematic file at path.
+func Load(path string) (*Schematic, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Decode(f)
+}
@@ -0,0 +1,204 @@
+package schematic
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/nickbryan/voxel/blocks"
+	"github.com/nickbryan/voxel/storage"
+)
+
+// numbered returns a schematic of the given size whose blocks cycle through a few block types, so that
+// every position can be told apart from its neighbours once moved.
+func numbered(w, h, d int, withLight bool) *Schematic {
+	types := []blocks.BlockType{blocks.Empty, blocks.Stone, blocks.Dirt, blocks.Glass, blocks.Log}
+
+	s := New(w, h, d)
+	if withLight {
+		s.Light = make([]byte, len(s.Blocks))
+	}
+
+	for i := range s.Blocks {
+		s.Blocks[i] = types[i%len(types)]
+		if withLight {
+			s.Light[i] = byte(i * 7)
+		}
+	}
+
+	return s
+}
+
+func assertEqual(t *testing.T, got, want *Schematic) {
+	t.Helper()
+
+	if got.Size != want.Size {
+		t.Fatalf("size is %v, want %v", got.Size, want.Size)
+	}
+
+	for i := range want.Blocks {
+		if got.Blocks[i] != want.Blocks[i] {
+			t.Fatalf("block %d is %d, want %d", i, got.Blocks[i], want.Blocks[i])
+		}
+	}
+
+	if (got.Light == nil) != (want.Light == nil) {
+		t.Fatalf("light captured is %v, want %v", got.Light != nil, want.Light != nil)
+	}
+
+	for i := range want.Light {
+		if got.Light[i] != want.Light[i] {
+			t.Fatalf("light %d is %#x, want %#x", i, got.Light[i], want.Light[i])
+		}
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	tests := []struct {
+		name      string
+		s         *Schematic
+		withLight bool
+	}{
+		{"empty", New(0, 0, 0), false},
+		{"single block", numbered(1, 1, 1, false), false},
+		{"blocks", numbered(5, 3, 7, false), false},
+		{"blocks and light", numbered(5, 3, 7, true), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Encode(&buf, tt.s); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := Decode(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assertEqual(t, got, tt.s)
+		})
+	}
+}
+
+// header returns the start of a schematic file up to and including its size.
+func header(w, h, d uint64) *bytes.Buffer {
+	var buf bytes.Buffer
+	buf.WriteString(magic)
+	binary.Write(&buf, binary.LittleEndian, FormatVersion)
+	buf.WriteByte(0)
+
+	for _, n := range []uint64{w, h, d} {
+		putUvarint(&buf, n)
+	}
+
+	return &buf
+}
+
+func putUvarint(buf *bytes.Buffer, v uint64) {
+	b := make([]byte, binary.MaxVarintLen64)
+	buf.Write(b[:binary.PutUvarint(b, v)])
+}
+
+// withPalette appends a palette of the given names to buf.
+func withPalette(buf *bytes.Buffer, names ...string) *bytes.Buffer {
+	putUvarint(buf, uint64(len(names)))
+	for _, name := range names {
+		putUvarint(buf, uint64(len(name)))
+		buf.WriteString(name)
+	}
+
+	return buf
+}
+
+func TestDecodeRejects(t *testing.T) {
+	outOfRange := withPalette(header(2, 1, 1), "stone")
+	bw := bufio.NewWriter(outOfRange)
+	storage.WriteRuns(bw, []uint64{0, 5})
+	bw.Flush()
+
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"wrong magic", []byte("VXRG\x01\x00\x00"), "not a schematic"},
+		{"other version", []byte("VXSC\x02\x00\x00"), "unsupported format version"},
+		{"oversize axis", header(MaxSize+1, 1, 1).Bytes(), "exceeds the maximum"},
+		{"oversize volume", header(MaxSize, MaxSize, MaxSize).Bytes(), "exceeds the maximum"},
+		{"palette larger than schematic", withPalette(header(1, 1, 1), "stone", "dirt").Bytes(), "larger than the schematic"},
+		{"long name", withPalette(header(1, 1, 1), strings.Repeat("a", maxNameLen+1)).Bytes(), "too long"},
+		{"unknown block", withPalette(header(1, 1, 1), "unobtainium").Bytes(), "unknown block"},
+		{"palette index out of range", outOfRange.Bytes(), "out of range"},
+		{"truncated blocks", withPalette(header(2, 1, 1), "stone").Bytes(), "decoding blocks"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode(bytes.NewReader(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Decode error is %v, want one mentioning %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeRejectsOversize(t *testing.T) {
+	s := &Schematic{Size: [3]int{MaxSize + 1, 1, 1}}
+
+	if err := Encode(&bytes.Buffer{}, s); err == nil {
+		t.Error("Encode saved a schematic larger than Decode will load")
+	}
+}
+
+func TestCaptureRejectsOversize(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b [3]int
+	}{
+		{"too long", [3]int{0, 0, 0}, [3]int{MaxSize, 0, 0}},
+		{"too long reversed", [3]int{MaxSize, 0, 0}, [3]int{0, 0, 0}},
+		{"too large a volume", [3]int{0, 0, 0}, [3]int{MaxSize - 1, MaxSize - 1, MaxSize - 1}},
+		{"wider than int", [3]int{-1 << 62, 0, 0}, [3]int{1<<63 - 1, 0, 0}},
+	}
+
+	// The size is checked before the world is touched, so no chunk manager is needed.
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Capture(nil, tt.a, tt.b, false); err == nil {
+				t.Error("Capture accepted a box too large to save")
+			}
+		})
+	}
+}
+
+func TestChunkStarts(t *testing.T) {
+	tests := []struct {
+		min, max int
+		want     []int
+	}{
+		{0, 0, []int{0}},
+		{5, 31, []int{5}},
+		{5, 32, []int{5, 32}},
+		{-1, 0, []int{-1, 0}},
+		{-33, 40, []int{-33, -32, 0, 32}},
+	}
+
+	for _, tt := range tests {
+		got := chunkStarts(tt.min, tt.max)
+		if len(got) != len(tt.want) {
+			t.Errorf("chunkStarts(%d, %d) = %v, want %v", tt.min, tt.max, got, tt.want)
+			continue
+		}
+
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("chunkStarts(%d, %d) = %v, want %v", tt.min, tt.max, got, tt.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,172 @@
+// Package schematic copies boxes of blocks out of the world so that they can be transformed, saved and
+// pasted elsewhere.
+package schematic
+
+import (
+	"fmt"
+
+	"github.com/nickbryan/voxel/blocks"
+)
+
+// Schematic is a box of blocks, with its minimum corner at the origin. Light is nil unless it was
+// captured, in which case it holds a torchlight and sunlight byte per block in the same packing as a
+// chunk's light map.
+type Schematic struct {
+	Size   [3]int
+	Blocks []blocks.BlockType
+	Light  []byte
+}
+
+// New creates an empty schematic of the given size.
+func New(w, h, d int) *Schematic {
+	return &Schematic{
+		Size:   [3]int{w, h, d},
+		Blocks: make([]blocks.BlockType, w*h*d),
+	}
+}
+
+func (s *Schematic) index(x, y, z int) int {
+	return x + y*s.Size[0] + z*s.Size[0]*s.Size[1]
+}
+
+// At returns the block at the given position within the schematic.
+func (s *Schematic) At(x, y, z int) blocks.BlockType {
+	return s.Blocks[s.index(x, y, z)]
+}
+
+// Set sets the block at the given position within the schematic.
+func (s *Schematic) Set(x, y, z int, bt blocks.BlockType) {
+	s.Blocks[s.index(x, y, z)] = bt
+}
+
+// LightAt returns the torch and sunlight levels captured at the given position. Both are 0 when no
+// light was captured.
+func (s *Schematic) LightAt(x, y, z int) (torchlight, sunlight uint8) {
+	if s.Light == nil {
+		return 0, 0
+	}
+
+	l := s.Light[s.index(x, y, z)]
+
+	return l & 0xF, l >> 4
+}
+
+// Capture copies the blocks in the box between the two corners, inclusive, out of the world. The
+// corners may be given in any order. Light is copied too when withLight is set. Every chunk the box
+// touches must be loaded, and the box must be small enough to save.
+func Capture(cm *blocks.ChunkManager, a, b [3]int, withLight bool) (*Schematic, error) {
+	var min, max, size [3]int
+	for i := range a {
+		min[i], max[i] = a[i], b[i]
+		if max[i] < min[i] {
+			min[i], max[i] = max[i], min[i]
+		}
+
+		// A box spanning more than the int range wraps negative, which checkSize rejects.
+		size[i] = max[i] - min[i] + 1
+	}
+
+	if err := checkSize(size); err != nil {
+		return nil, err
+	}
+
+	for _, wz := range chunkStarts(min[2], max[2]) {
+		for _, wy := range chunkStarts(min[1], max[1]) {
+			for _, wx := range chunkStarts(min[0], max[0]) {
+				if _, ok := cm.BlockAt(wx, wy, wz); !ok {
+					return nil, fmt.Errorf("schematic: chunk containing %d, %d, %d is not loaded", wx, wy, wz)
+				}
+			}
+		}
+	}
+
+	s := New(size[0], size[1], size[2])
+	if withLight {
+		s.Light = make([]byte, len(s.Blocks))
+	}
+
+	for z := 0; z < size[2]; z++ {
+		for y := 0; y < size[1]; y++ {
+			for x := 0; x < size[0]; x++ {
+				wx, wy, wz := min[0]+x, min[1]+y, min[2]+z
+
+				bt, ok := cm.BlockAt(wx, wy, wz)
+				if !ok {
+					return nil, fmt.Errorf("schematic: chunk containing %d, %d, %d is not loaded", wx, wy, wz)
+				}
+
+				i := s.index(x, y, z)
+				s.Blocks[i] = bt
+
+				if withLight {
+					tl, sl, _ := cm.LightAt(wx, wy, wz)
+					s.Light[i] = tl | sl<<4
+				}
+			}
+		}
+	}
+
+	return s, nil
+}
+
+// chunkStarts returns min and every chunk boundary after it up to max, which between them lie in every
+// chunk the range from min to max touches along one axis.
+func chunkStarts(min, max int) []int {
+	starts := []int{min}
+	for c := min - mod(min, blocks.ChunkSize) + blocks.ChunkSize; c <= max; c += blocks.ChunkSize {
+		starts = append(starts, c)
+	}
+
+	return starts
+}
+
+func mod(a, b int) int {
+	m := a % b
+	if m < 0 {
+		m += b
+	}
+
+	return m
+}
+
+// Paste writes the schematic into the world with its minimum corner at the given world block
+// coordinates. Empty blocks are skipped unless includeAir is set, so that pasting does not carve
+// the schematic's bounding box out of the terrain. When light was captured, torches are placed wherever
+// the torchlight was at its maximum without a light emitting block to explain it; other light is
+// recomputed by the world. It returns the number of blocks placed and the number skipped because their
+// chunk is not loaded.
+func (s *Schematic) Paste(cm *blocks.ChunkManager, x, y, z int, includeAir bool) (placed, skipped int) {
+	for sz := 0; sz < s.Size[2]; sz++ {
+		for sy := 0; sy < s.Size[1]; sy++ {
+			for sx := 0; sx < s.Size[0]; sx++ {
+				bt := s.At(sx, sy, sz)
+				if bt == blocks.Empty && !includeAir {
+					continue
+				}
+
+				if cm.SetBlock(x+sx, y+sy, z+sz, bt) {
+					placed++
+				} else {
+					skipped++
+				}
+			}
+		}
+	}
+
+	if s.Light == nil {
+		return placed, skipped
+	}
+
+	for sz := 0; sz < s.Size[2]; sz++ {
+		for sy := 0; sy < s.Size[1]; sy++ {
+			for sx := 0; sx < s.Size[0]; sx++ {
+				tl, _ := s.LightAt(sx, sy, sz)
+				if tl == blocks.MaxLightLevel && s.At(sx, sy, sz).Definition().LightEmission < blocks.MaxLightLevel {
+					cm.PlaceTorch(x+sx, y+sy, z+sz)
+				}
+			}
+		}
+	}
+
+	return placed, skipped
+}
@@ -0,0 +1,61 @@
+package schematic
+
+// Axis identifies one of the three world axes.
+type Axis int
+
+const (
+	AxisX Axis = iota
+	AxisY
+	AxisZ
+)
+
+// transform returns a schematic of the given size where each block of s is moved to the position
+// returned by fn.
+func (s *Schematic) transform(size [3]int, fn func(x, y, z int) (int, int, int)) *Schematic {
+	t := New(size[0], size[1], size[2])
+	if s.Light != nil {
+		t.Light = make([]byte, len(t.Blocks))
+	}
+
+	for z := 0; z < s.Size[2]; z++ {
+		for y := 0; y < s.Size[1]; y++ {
+			for x := 0; x < s.Size[0]; x++ {
+				i, j := s.index(x, y, z), t.index(fn(x, y, z))
+
+				t.Blocks[j] = s.Blocks[i]
+				if s.Light != nil {
+					t.Light[j] = s.Light[i]
+				}
+			}
+		}
+	}
+
+	return t
+}
+
+// Rotate returns a copy of the schematic turned about the Y axis by the given number of quarter turns.
+// Each turn takes +X to +Z; negative turns rotate the other way.
+func (s *Schematic) Rotate(turns int) *Schematic {
+	w, h, d := s.Size[0], s.Size[1], s.Size[2]
+
+	switch (turns%4 + 4) % 4 {
+	case 1:
+		return s.transform([3]int{d, h, w}, func(x, y, z int) (int, int, int) { return d - 1 - z, y, x })
+	case 2:
+		return s.transform(s.Size, func(x, y, z int) (int, int, int) { return w - 1 - x, y, d - 1 - z })
+	case 3:
+		return s.transform([3]int{d, h, w}, func(x, y, z int) (int, int, int) { return z, y, w - 1 - x })
+	default:
+		return s.transform(s.Size, func(x, y, z int) (int, int, int) { return x, y, z })
+	}
+}
+
+// Mirror returns a copy of the schematic flipped along the given axis.
+func (s *Schematic) Mirror(axis Axis) *Schematic {
+	return s.transform(s.Size, func(x, y, z int) (int, int, int) {
+		p := [3]int{x, y, z}
+		p[axis] = s.Size[axis] - 1 - p[axis]
+
+		return p[0], p[1], p[2]
+	})
+}
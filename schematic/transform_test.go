@@ -0,0 +1,96 @@
+package schematic
+
+import (
+	"testing"
+
+	"github.com/nickbryan/voxel/blocks"
+)
+
+func TestRotate(t *testing.T) {
+	s := New(3, 2, 4)
+	s.Set(0, 0, 0, blocks.Stone)
+	s.Set(2, 1, 0, blocks.Dirt)
+	s.Set(0, 0, 3, blocks.Log)
+
+	tests := []struct {
+		turns int
+		size  [3]int
+		want  map[[3]int]blocks.BlockType
+	}{
+		{0, [3]int{3, 2, 4}, map[[3]int]blocks.BlockType{{0, 0, 0}: blocks.Stone, {2, 1, 0}: blocks.Dirt, {0, 0, 3}: blocks.Log}},
+		{1, [3]int{4, 2, 3}, map[[3]int]blocks.BlockType{{3, 0, 0}: blocks.Stone, {3, 1, 2}: blocks.Dirt, {0, 0, 0}: blocks.Log}},
+		{2, [3]int{3, 2, 4}, map[[3]int]blocks.BlockType{{2, 0, 3}: blocks.Stone, {0, 1, 3}: blocks.Dirt, {2, 0, 0}: blocks.Log}},
+		{3, [3]int{4, 2, 3}, map[[3]int]blocks.BlockType{{0, 0, 2}: blocks.Stone, {0, 1, 0}: blocks.Dirt, {3, 0, 2}: blocks.Log}},
+		{-1, [3]int{4, 2, 3}, map[[3]int]blocks.BlockType{{0, 0, 2}: blocks.Stone, {0, 1, 0}: blocks.Dirt, {3, 0, 2}: blocks.Log}},
+		{5, [3]int{4, 2, 3}, map[[3]int]blocks.BlockType{{3, 0, 0}: blocks.Stone, {3, 1, 2}: blocks.Dirt, {0, 0, 0}: blocks.Log}},
+	}
+
+	for _, tt := range tests {
+		r := s.Rotate(tt.turns)
+
+		if r.Size != tt.size {
+			t.Errorf("Rotate(%d) size is %v, want %v", tt.turns, r.Size, tt.size)
+			continue
+		}
+
+		assertBlocks(t, r, tt.want)
+	}
+}
+
+func TestRotateFullTurn(t *testing.T) {
+	s := numbered(3, 2, 5, true)
+
+	r := s
+	for i := 0; i < 4; i++ {
+		r = r.Rotate(1)
+	}
+	assertEqual(t, r, s)
+
+	assertEqual(t, s.Rotate(1).Rotate(-1), s)
+	assertEqual(t, s.Rotate(2).Rotate(2), s)
+}
+
+func TestMirror(t *testing.T) {
+	s := New(3, 2, 4)
+	s.Set(0, 0, 0, blocks.Stone)
+	s.Set(2, 1, 3, blocks.Dirt)
+
+	tests := []struct {
+		axis Axis
+		want map[[3]int]blocks.BlockType
+	}{
+		{AxisX, map[[3]int]blocks.BlockType{{2, 0, 0}: blocks.Stone, {0, 1, 3}: blocks.Dirt}},
+		{AxisY, map[[3]int]blocks.BlockType{{0, 1, 0}: blocks.Stone, {2, 0, 3}: blocks.Dirt}},
+		{AxisZ, map[[3]int]blocks.BlockType{{0, 0, 3}: blocks.Stone, {2, 1, 0}: blocks.Dirt}},
+	}
+
+	for _, tt := range tests {
+		m := s.Mirror(tt.axis)
+
+		if m.Size != s.Size {
+			t.Errorf("Mirror(%d) size is %v, want %v", tt.axis, m.Size, s.Size)
+			continue
+		}
+
+		assertBlocks(t, m, tt.want)
+
+		// Mirroring twice along the same axis is the original, light included.
+		l := numbered(3, 2, 4, true)
+		assertEqual(t, l.Mirror(tt.axis).Mirror(tt.axis), l)
+	}
+}
+
+// assertBlocks fails the test unless s holds exactly the given blocks, with every other block empty.
+func assertBlocks(t *testing.T, s *Schematic, want map[[3]int]blocks.BlockType) {
+	t.Helper()
+
+	for z := 0; z < s.Size[2]; z++ {
+		for y := 0; y < s.Size[1]; y++ {
+			for x := 0; x < s.Size[0]; x++ {
+				if got, w := s.At(x, y, z), want[[3]int{x, y, z}]; got != w {
+					t.Errorf("block %d, %d, %d is %d, want %d", x, y, z, got, w)
+				}
+			}
+		}
+	}
+}
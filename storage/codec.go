@@ -0,0 +1,205 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/nickbryan/voxel/blocks"
+)
+
+// maxNameLen bounds the length of a block name read from a chunk's palette.
+const maxNameLen = 256
+
+// EncodeChunk writes a chunk's blocks and light map to w. Blocks are stored as indexes into a palette of
+// block names so that a chunk still loads after the registry assigns different types. The palette is
+// written as a count followed by each name's length and bytes, then the block indexes and light bytes
+// are each stored as a sequence of runs of identical values, which keeps mostly empty or mostly solid
+// chunks to a few bytes.
+func EncodeChunk(w io.Writer, bc blocks.BlockStorage, lm blocks.LightMapContainer) error {
+	bw := bufio.NewWriter(w)
+
+	palette := make(map[blocks.BlockType]uint64)
+	var names []string
+
+	values := make([]uint64, 0, blocks.ChunkSizeCubed)
+	eachBlock(func(x, y, z int) {
+		bt := bc.Lookup(x, y, z)
+
+		p, ok := palette[bt]
+		if !ok {
+			p = uint64(len(names))
+			palette[bt] = p
+			names = append(names, bt.Definition().Name)
+		}
+
+		values = append(values, p)
+	})
+
+	buf := make([]byte, binary.MaxVarintLen64)
+	putUvarint := func(v uint64) {
+		bw.Write(buf[:binary.PutUvarint(buf, v)])
+	}
+
+	putUvarint(uint64(len(names)))
+	for _, name := range names {
+		putUvarint(uint64(len(name)))
+		bw.WriteString(name)
+	}
+
+	if err := WriteRuns(bw, values); err != nil {
+		return err
+	}
+
+	values = values[:len(lm)]
+	for i, l := range lm {
+		values[i] = uint64(l)
+	}
+	if err := WriteRuns(bw, values); err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+// DecodeChunk reads a chunk written by EncodeChunk into bc and lm, resolving block names against
+// blocks.DefaultRegistry. The light map must be ChunkSizeCubed long.
+func DecodeChunk(r io.Reader, bc blocks.BlockStorage, lm blocks.LightMapContainer) error {
+	br := bufio.NewReader(r)
+
+	palette, err := readPalette(br)
+	if err != nil {
+		return fmt.Errorf("storage: decoding palette: %v", err)
+	}
+
+	values := make([]uint64, blocks.ChunkSizeCubed)
+	err = ReadRuns(br, len(values), func(i int, v uint64) {
+		values[i] = v
+	})
+	if err != nil {
+		return fmt.Errorf("storage: decoding blocks: %v", err)
+	}
+
+	for _, v := range values {
+		if v >= uint64(len(palette)) {
+			return fmt.Errorf("storage: decoding blocks: palette index %d out of range", v)
+		}
+	}
+
+	eachBlock(func(x, y, z int) {
+		bc.Set(x, y, z, palette[values[0]])
+		values = values[1:]
+	})
+
+	err = ReadRuns(br, len(lm), func(i int, v uint64) {
+		lm[i] = byte(v)
+	})
+	if err != nil {
+		return fmt.Errorf("storage: decoding light map: %v", err)
+	}
+
+	return nil
+}
+
+func readPalette(r *bufio.Reader) ([]blocks.BlockType, error) {
+	count, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if count > blocks.ChunkSizeCubed {
+		return nil, fmt.Errorf("palette of %d entries is larger than a chunk", count)
+	}
+
+	palette := make([]blocks.BlockType, 0, count)
+	for i := uint64(0); i < count; i++ {
+		l, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+
+		if l > maxNameLen {
+			return nil, fmt.Errorf("block name of %d bytes is too long", l)
+		}
+
+		name := make([]byte, l)
+		if _, err := io.ReadFull(r, name); err != nil {
+			return nil, err
+		}
+
+		bt, ok := blocks.DefaultRegistry.Lookup(string(name))
+		if !ok {
+			return nil, fmt.Errorf("unknown block %q", name)
+		}
+
+		palette = append(palette, bt)
+	}
+
+	return palette, nil
+}
+
+// WriteRuns writes values as a sequence of uvarint run lengths, each followed by the repeated value.
+func WriteRuns(w *bufio.Writer, values []uint64) error {
+	buf := make([]byte, binary.MaxVarintLen64)
+
+	put := func(v uint64) error {
+		n := binary.PutUvarint(buf, v)
+		_, err := w.Write(buf[:n])
+		return err
+	}
+
+	for i := 0; i < len(values); {
+		j := i + 1
+		for j < len(values) && values[j] == values[i] {
+			j++
+		}
+
+		if err := put(uint64(j - i)); err != nil {
+			return err
+		}
+		if err := put(values[i]); err != nil {
+			return err
+		}
+
+		i = j
+	}
+
+	return nil
+}
+
+// ReadRuns reads n values written by WriteRuns, passing each to set along with its index.
+func ReadRuns(r *bufio.Reader, n int, set func(i int, v uint64)) error {
+	for i := 0; i < n; {
+		run, err := binary.ReadUvarint(r)
+		if err != nil {
+			return err
+		}
+
+		v, err := binary.ReadUvarint(r)
+		if err != nil {
+			return err
+		}
+
+		if run == 0 || run > uint64(n-i) {
+			return fmt.Errorf("run of %d at %d overflows %d values", run, i, n)
+		}
+
+		for end := i + int(run); i < end; i++ {
+			set(i, v)
+		}
+	}
+
+	return nil
+}
+
+// eachBlock calls fn for every position in a chunk in the order the blocks are stored.
+func eachBlock(fn func(x, y, z int)) {
+	for z := 0; z < blocks.ChunkSize; z++ {
+		for y := 0; y < blocks.ChunkSize; y++ {
+			for x := 0; x < blocks.ChunkSize; x++ {
+				fn(x, y, z)
+			}
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/nickbryan/voxel/blocks"
+)
+
+func TestEncodeChunkStoresBlockNames(t *testing.T) {
+	c := uniformChunk(blocks.Stone)
+	c.blocks.Set(1, 2, 3, blocks.Leaves)
+
+	var buf bytes.Buffer
+	if err := EncodeChunk(&buf, c.blocks, c.light); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"stone", "leaves"} {
+		if !strings.Contains(buf.String(), name) {
+			t.Errorf("encoded chunk does not name %q", name)
+		}
+	}
+
+	got := emptyChunk()
+	if err := DecodeChunk(bytes.NewReader(buf.Bytes()), got.blocks, got.light); err != nil {
+		t.Fatal(err)
+	}
+
+	if bt := got.blocks.Lookup(1, 2, 3); bt != blocks.Leaves {
+		t.Errorf("decoded block is %d, want leaves (%d)", bt, blocks.Leaves)
+	}
+
+	// A block that is no longer registered cannot be loaded as whatever now has its type.
+	renamed := bytes.Replace(buf.Bytes(), []byte("leaves"), []byte("leafxx"), 1)
+	if err := DecodeChunk(bytes.NewReader(renamed), got.blocks, got.light); err == nil {
+		t.Error("DecodeChunk accepted an unknown block name")
+	}
+}
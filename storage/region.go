@@ -0,0 +1,257 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/nickbryan/voxel/blocks"
+)
+
+// RegionSize is the number of chunks along each axis of a region file.
+const RegionSize = 16
+
+// FormatVersion is written to the header of every region file and bumped whenever the layout of the
+// file or the chunk encoding changes.
+const FormatVersion uint16 = 1
+
+const (
+	regionChunks = RegionSize * RegionSize * RegionSize
+	regionMagic  = "VXRG"
+	headerSize   = len(regionMagic) + 2
+	tableSize    = regionChunks * 8
+	dataStart    = headerSize + tableSize
+)
+
+// RegionStore saves chunks to region files in a directory, each holding a RegionSize cube of chunks.
+// A region file starts with a header of the magic bytes and FormatVersion, followed by a table with the
+// offset and length of every chunk in the region and then the encoded chunks themselves. Saving a chunk
+// writes it into free space, or onto the end of the file, before pointing its table entry at the new
+// copy, so the rest of the region is never rewritten and the previous copy survives the game stopping
+// part way through.
+type RegionStore struct {
+	dir string
+
+	// mu serialises file access and guards tables, which caches the table of every region file opened.
+	mu     sync.Mutex
+	tables map[blocks.ChunkCoord]*regionTable
+}
+
+type tableEntry struct {
+	Offset, Length uint32
+}
+
+type regionTable [regionChunks]tableEntry
+
+// NewRegionStore creates a store that keeps its region files in dir, creating it if needed.
+func NewRegionStore(dir string) (*RegionStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return &RegionStore{dir: dir, tables: make(map[blocks.ChunkCoord]*regionTable)}, nil
+}
+
+// LoadChunk reads the chunk at coord into bc and lm. It reports false if the chunk has never been saved.
+func (s *RegionStore) LoadChunk(coord blocks.ChunkCoord, bc blocks.BlockStorage, lm blocks.LightMapContainer) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, t, err := s.open(regionOf(coord), false)
+	if err != nil || f == nil {
+		return false, err
+	}
+	defer f.Close()
+
+	e := t[chunkIndex(coord)]
+	if e.Length == 0 {
+		return false, nil
+	}
+
+	data := make([]byte, e.Length)
+	if _, err := f.ReadAt(data, int64(e.Offset)); err != nil {
+		return false, fmt.Errorf("storage: chunk %v: %v", coord, err)
+	}
+
+	if err := DecodeChunk(bytes.NewReader(data), bc, lm); err != nil {
+		return false, fmt.Errorf("storage: chunk %v: %v", coord, err)
+	}
+
+	return true, nil
+}
+
+// SaveChunk writes the chunk at coord to its region file, replacing any earlier copy.
+func (s *RegionStore) SaveChunk(coord blocks.ChunkCoord, bc blocks.BlockStorage, lm blocks.LightMapContainer) error {
+	var buf bytes.Buffer
+	if err := EncodeChunk(&buf, bc, lm); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, t, err := s.open(regionOf(coord), true)
+	if err != nil {
+		return err
+	}
+
+	i := chunkIndex(coord)
+	e := tableEntry{Offset: t.allocate(uint32(buf.Len())), Length: uint32(buf.Len())}
+
+	if _, err := f.WriteAt(buf.Bytes(), int64(e.Offset)); err != nil {
+		f.Close()
+		return err
+	}
+
+	var entry [8]byte
+	binary.LittleEndian.PutUint32(entry[:], e.Offset)
+	binary.LittleEndian.PutUint32(entry[4:], e.Length)
+
+	if _, err := f.WriteAt(entry[:], int64(headerSize+i*8)); err != nil {
+		f.Close()
+		return err
+	}
+
+	t[i] = e
+
+	return f.Close()
+}
+
+func (s *RegionStore) path(rc blocks.ChunkCoord) string {
+	return filepath.Join(s.dir, fmt.Sprintf("r.%d.%d.%d.vxr", rc.X, rc.Y, rc.Z))
+}
+
+// open opens a region file along with its table. A missing file is created when create is set, and
+// otherwise gives a nil file and no error.
+func (s *RegionStore) open(rc blocks.ChunkCoord, create bool) (*os.File, *regionTable, error) {
+	flag := os.O_RDONLY
+	if create {
+		flag = os.O_RDWR | os.O_CREATE
+	}
+
+	f, err := os.OpenFile(s.path(rc), flag, 0644)
+	if os.IsNotExist(err) && !create {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if t, ok := s.tables[rc]; ok {
+		return f, t, nil
+	}
+
+	t, err := s.readTable(f, rc)
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+
+	s.tables[rc] = t
+
+	return f, t, nil
+}
+
+// readTable reads the header and table of a region file, writing them first if the file is new.
+func (s *RegionStore) readTable(f *os.File, rc blocks.ChunkCoord) (*regionTable, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	t := &regionTable{}
+
+	if info.Size() == 0 {
+		header := make([]byte, dataStart)
+		copy(header, regionMagic)
+		binary.LittleEndian.PutUint16(header[len(regionMagic):], FormatVersion)
+
+		if _, err := f.WriteAt(header, 0); err != nil {
+			return nil, err
+		}
+
+		return t, nil
+	}
+
+	header := make([]byte, dataStart)
+	if _, err := f.ReadAt(header, 0); err != nil || string(header[:len(regionMagic)]) != regionMagic {
+		return nil, fmt.Errorf("storage: %s is not a region file", s.path(rc))
+	}
+
+	if v := binary.LittleEndian.Uint16(header[len(regionMagic):]); v != FormatVersion {
+		return nil, fmt.Errorf("storage: %s has format version %d, expected %d", s.path(rc), v, FormatVersion)
+	}
+
+	for i := range t {
+		e := tableEntry{
+			Offset: binary.LittleEndian.Uint32(header[headerSize+i*8:]),
+			Length: binary.LittleEndian.Uint32(header[headerSize+i*8+4:]),
+		}
+
+		if e.Length == 0 {
+			continue
+		}
+
+		if e.Offset < uint32(dataStart) || int64(e.Offset)+int64(e.Length) > info.Size() {
+			return nil, fmt.Errorf("storage: %s has a truncated chunk at index %d", s.path(rc), i)
+		}
+
+		t[i] = e
+	}
+
+	return t, nil
+}
+
+// allocate returns the offset of the first gap in the region's data of at least n bytes. The space
+// held by every chunk counts as used, including the copy of the chunk being saved, so that copy is never
+// overwritten before its table entry has been replaced.
+func (t *regionTable) allocate(n uint32) uint32 {
+	var used []tableEntry
+	for _, e := range t {
+		if e.Length > 0 {
+			used = append(used, e)
+		}
+	}
+
+	sort.Slice(used, func(i, j int) bool {
+		return used[i].Offset < used[j].Offset
+	})
+
+	offset := uint32(dataStart)
+	for _, e := range used {
+		if e.Offset >= offset && e.Offset-offset >= n {
+			return offset
+		}
+
+		if end := e.Offset + e.Length; end > offset {
+			offset = end
+		}
+	}
+
+	return offset
+}
+
+func regionOf(c blocks.ChunkCoord) blocks.ChunkCoord {
+	return blocks.ChunkCoord{X: floorDiv(c.X), Y: floorDiv(c.Y), Z: floorDiv(c.Z)}
+}
+
+func chunkIndex(c blocks.ChunkCoord) int {
+	x := c.X - floorDiv(c.X)*RegionSize
+	y := c.Y - floorDiv(c.Y)*RegionSize
+	z := c.Z - floorDiv(c.Z)*RegionSize
+
+	return int((x*RegionSize+y)*RegionSize + z)
+}
+
+func floorDiv(a int32) int32 {
+	q := a / RegionSize
+	if a%RegionSize < 0 {
+		q--
+	}
+
+	return q
+}
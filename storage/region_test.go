@@ -0,0 +1,257 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nickbryan/voxel/blocks"
+)
+
+type testChunk struct {
+	blocks *blocks.PaletteContainer
+	light  blocks.LightMapContainer
+}
+
+func emptyChunk() testChunk {
+	return testChunk{blocks.NewPaletteContainer(blocks.Empty), make(blocks.LightMapContainer, blocks.ChunkSizeCubed)}
+}
+
+func uniformChunk(bt blocks.BlockType) testChunk {
+	c := emptyChunk()
+	c.blocks = blocks.NewPaletteContainer(bt)
+
+	for i := range c.light {
+		c.light[i] = 0xF0
+	}
+
+	return c
+}
+
+func mixedChunk(seed int64) testChunk {
+	c := emptyChunk()
+	rng := rand.New(rand.NewSource(seed))
+	types := []blocks.BlockType{blocks.Empty, blocks.Stone, blocks.Dirt, blocks.Water, blocks.Glass, blocks.Log}
+
+	eachBlock(func(x, y, z int) {
+		c.blocks.Set(x, y, z, types[rng.Intn(len(types))])
+	})
+
+	for i := range c.light {
+		c.light[i] = byte(rng.Intn(256))
+	}
+
+	return c
+}
+
+func assertChunk(t *testing.T, s *RegionStore, coord blocks.ChunkCoord, want testChunk) {
+	t.Helper()
+
+	got := emptyChunk()
+
+	ok, err := s.LoadChunk(coord, got.blocks, got.light)
+	if err != nil {
+		t.Fatalf("LoadChunk(%v): %v", coord, err)
+	}
+
+	if !ok {
+		t.Fatalf("LoadChunk(%v) found no chunk", coord)
+	}
+
+	eachBlock(func(x, y, z int) {
+		if g, w := got.blocks.Lookup(x, y, z), want.blocks.Lookup(x, y, z); g != w {
+			t.Fatalf("chunk %v block %d, %d, %d is %d, want %d", coord, x, y, z, g, w)
+		}
+	})
+
+	for i := range want.light {
+		if got.light[i] != want.light[i] {
+			t.Fatalf("chunk %v light %d is %#x, want %#x", coord, i, got.light[i], want.light[i])
+		}
+	}
+}
+
+func TestRegionStoreRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := NewRegionStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Chunks either side of the region boundaries at 0 and -16 along every axis.
+	chunks := map[blocks.ChunkCoord]testChunk{
+		{X: 0, Y: 0, Z: 0}:       emptyChunk(),
+		{X: -1, Y: 0, Z: 0}:      uniformChunk(blocks.Stone),
+		{X: 0, Y: -1, Z: 0}:      mixedChunk(1),
+		{X: 0, Y: 0, Z: -1}:      mixedChunk(2),
+		{X: 15, Y: 15, Z: 15}:    uniformChunk(blocks.Water),
+		{X: 16, Y: 0, Z: 0}:      mixedChunk(3),
+		{X: -16, Y: -16, Z: -16}: mixedChunk(4),
+		{X: -17, Y: -17, Z: -17}: mixedChunk(5),
+	}
+
+	for coord, c := range chunks {
+		if err := s.SaveChunk(coord, c.blocks, c.light); err != nil {
+			t.Fatalf("SaveChunk(%v): %v", coord, err)
+		}
+	}
+
+	for coord, c := range chunks {
+		assertChunk(t, s, coord, c)
+	}
+
+	// A fresh store has to read everything back from the region files rather than its cached tables.
+	s, err = NewRegionStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for coord, c := range chunks {
+		assertChunk(t, s, coord, c)
+	}
+
+	c := emptyChunk()
+	if ok, err := s.LoadChunk(blocks.ChunkCoord{X: 1, Y: 2, Z: 3}, c.blocks, c.light); ok || err != nil {
+		t.Errorf("LoadChunk of an unsaved chunk = %v, %v; want false, nil", ok, err)
+	}
+
+	if ok, err := s.LoadChunk(blocks.ChunkCoord{X: 100, Y: 100, Z: 100}, c.blocks, c.light); ok || err != nil {
+		t.Errorf("LoadChunk in a missing region = %v, %v; want false, nil", ok, err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.vxr"))
+	if len(files) != 7 {
+		t.Errorf("wrote %d region files, want 7", len(files))
+	}
+}
+
+func TestRegionStoreOverwrite(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := NewRegionStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, b := blocks.ChunkCoord{X: 1}, blocks.ChunkCoord{X: 2}
+	other := mixedChunk(10)
+
+	if err := s.SaveChunk(b, other.blocks, other.light); err != nil {
+		t.Fatal(err)
+	}
+
+	// Alternate between a small and a large copy of the chunk so that it has to move around the file.
+	var last testChunk
+	for i := 0; i < 20; i++ {
+		last = uniformChunk(blocks.Stone)
+		if i%2 == 1 {
+			last = mixedChunk(int64(i))
+		}
+
+		if err := s.SaveChunk(a, last.blocks, last.light); err != nil {
+			t.Fatal(err)
+		}
+
+		assertChunk(t, s, a, last)
+		assertChunk(t, s, b, other)
+	}
+
+	info, err := os.Stat(s.path(regionOf(a)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The space freed by earlier copies is reused, leaving room for at most two large copies of the
+	// chunk alongside the other one.
+	var buf bytes.Buffer
+	if err := EncodeChunk(&buf, other.blocks, other.light); err != nil {
+		t.Fatal(err)
+	}
+
+	if max := int64(dataStart + 4*buf.Len()); info.Size() > max {
+		t.Errorf("region file grew to %d bytes after repeated saves, want at most %d", info.Size(), max)
+	}
+
+	s, err = NewRegionStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertChunk(t, s, a, last)
+	assertChunk(t, s, b, other)
+}
+
+func TestRegionStoreRejectsOtherVersions(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := NewRegionStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := emptyChunk()
+	if err := s.SaveChunk(blocks.ChunkCoord{}, c.blocks, c.light); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.OpenFile(s.path(blocks.ChunkCoord{}), os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteAt([]byte{byte(FormatVersion + 1), 0}, int64(len(regionMagic)))
+	f.Close()
+
+	s, err = NewRegionStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.LoadChunk(blocks.ChunkCoord{}, c.blocks, c.light); err == nil {
+		t.Error("LoadChunk read a region file with another format version")
+	}
+}
+
+func TestRegionStoreTruncatedRecord(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := NewRegionStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	coord := blocks.ChunkCoord{X: 3, Y: 1, Z: 2}
+	c := mixedChunk(20)
+
+	if err := s.SaveChunk(coord, c.blocks, c.light); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.OpenFile(s.path(regionOf(coord)), os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Halve the length in the chunk's table entry, so that its record stops partway through.
+	entry := int64(headerSize + chunkIndex(coord)*8)
+	var length [4]byte
+	if _, err := f.ReadAt(length[:], entry+4); err != nil {
+		t.Fatal(err)
+	}
+	binary.LittleEndian.PutUint32(length[:], binary.LittleEndian.Uint32(length[:])/2)
+	f.WriteAt(length[:], entry+4)
+	f.Close()
+
+	s, err = NewRegionStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := emptyChunk()
+	if ok, err := s.LoadChunk(coord, got.blocks, got.light); ok || err == nil {
+		t.Errorf("LoadChunk of a truncated record = %v, %v; want false and an error", ok, err)
+	}
+}
@@ -0,0 +1,65 @@
+package vox
+
+import (
+	"github.com/nickbryan/voxel/blocks"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+// World is where models are stamped. blocks.ChunkManager implements it.
+type World interface {
+	SetBlock(x, y, z int, bt blocks.BlockType) bool
+}
+
+// Mapping gives the block type to use for each palette index. Empty leaves the cell untouched.
+type Mapping [256]blocks.BlockType
+
+// NearestBlocks maps every colour in the palette to the visible, solid block registered in r whose
+// top face colour is closest to it. Indexes whose colour is fully transparent map to Empty.
+func NearestBlocks(r *blocks.Registry, f *File) Mapping {
+	var m Mapping
+
+	for i := 1; i < len(f.Palette); i++ {
+		c := f.Palette[i]
+		if c.A == 0 {
+			continue
+		}
+
+		want := mgl64.Vec3{float64(c.R) / 255, float64(c.G) / 255, float64(c.B) / 255}
+		best := -1.0
+
+		for bt := blocks.BlockType(1); int(bt) < r.Len(); bt++ {
+			def := r.Get(bt)
+			if def.Invisible || !def.Solid {
+				continue
+			}
+
+			if d := def.Colors[blocks.FaceTop].Sub(want).Len(); best < 0 || d < best {
+				m[i], best = bt, d
+			}
+		}
+	}
+
+	return m
+}
+
+// Stamp writes the model into w with its minimum corner at the given world block coordinates. The
+// model's Z axis becomes the world's Y axis so that it stands the right way up. It returns the number
+// of blocks placed and the number skipped because their chunk is not loaded.
+func (m *Model) Stamp(w World, x, y, z int, mapping Mapping) (placed, skipped int) {
+	for _, v := range m.Voxels {
+		bt := mapping[v.Index]
+		if bt == blocks.Empty {
+			continue
+		}
+
+		// MagicaVoxel's Y axis points away from the viewer, so flip it to keep the model's handedness.
+		if w.SetBlock(x+int(v.X), y+int(v.Z), z+m.Size[1]-1-int(v.Y), bt) {
+			placed++
+		} else {
+			skipped++
+		}
+	}
+
+	return placed, skipped
+}
@@ -0,0 +1,118 @@
+package vox
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/nickbryan/voxel/blocks"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+// recordingWorld records the blocks set in it. Blocks below y=0 are treated as not loaded.
+type recordingWorld map[[3]int]blocks.BlockType
+
+func (w recordingWorld) SetBlock(x, y, z int, bt blocks.BlockType) bool {
+	if y < 0 {
+		return false
+	}
+
+	w[[3]int{x, y, z}] = bt
+
+	return true
+}
+
+func TestStamp(t *testing.T) {
+	m := Model{
+		Size: [3]int{2, 3, 4},
+		Voxels: []Voxel{
+			{X: 0, Y: 0, Z: 0, Index: 1},
+			{X: 1, Y: 0, Z: 0, Index: 2},
+			{X: 0, Y: 2, Z: 0, Index: 1},
+			{X: 0, Y: 0, Z: 3, Index: 2},
+			{X: 1, Y: 1, Z: 1, Index: 3},
+		},
+	}
+
+	var mapping Mapping
+	mapping[1], mapping[2] = blocks.Stone, blocks.Dirt
+
+	w := recordingWorld{}
+	placed, skipped := m.Stamp(w, 10, 20, 30, mapping)
+
+	// Model Z is world Y, and model Y runs against world Z: Size[1] 3 puts model Y 0 at world Z 32.
+	want := recordingWorld{
+		{10, 20, 32}: blocks.Stone,
+		{11, 20, 32}: blocks.Dirt,
+		{10, 20, 30}: blocks.Stone,
+		{10, 23, 32}: blocks.Dirt,
+	}
+
+	if placed != len(want) || skipped != 0 {
+		t.Errorf("Stamp placed %d and skipped %d, want %d and 0", placed, skipped, len(want))
+	}
+
+	if len(w) != len(want) {
+		t.Errorf("Stamp set %d blocks, want %d: %v", len(w), len(want), w)
+	}
+
+	for p, bt := range want {
+		if got, ok := w[p]; !ok || got != bt {
+			t.Errorf("block at %v is %d, want %d", p, got, bt)
+		}
+	}
+
+	// Only the voxel at the top of the model lands above y=0.
+	if placed, skipped := m.Stamp(recordingWorld{}, 0, -1, 0, mapping); placed != 1 || skipped != 3 {
+		t.Errorf("Stamp mostly below the loaded world placed %d and skipped %d, want 1 and 3", placed, skipped)
+	}
+}
+
+func TestNearestBlocks(t *testing.T) {
+	r := blocks.NewRegistry()
+
+	red := r.MustRegister(blocks.BlockDefinition{Name: "red", Solid: true, Colors: uniform(1, 0, 0)})
+	green := r.MustRegister(blocks.BlockDefinition{Name: "green", Solid: true, Colors: uniform(0, 1, 0)})
+	r.MustRegister(blocks.BlockDefinition{Name: "ghost", Solid: true, Invisible: true, Colors: uniform(0, 0, 1)})
+	r.MustRegister(blocks.BlockDefinition{Name: "mist", Colors: uniform(0, 0, 0.9)})
+	grey := r.MustRegister(blocks.BlockDefinition{Name: "grey", Solid: true, Colors: uniform(0.5, 0.5, 0.5)})
+
+	f := &File{Palette: DefaultPalette}
+	f.Palette[1] = color.RGBA{255, 0, 0, 255}
+	f.Palette[2] = color.RGBA{200, 60, 0, 255}
+	f.Palette[3] = color.RGBA{0, 255, 0, 0}
+	f.Palette[4] = color.RGBA{10, 240, 10, 128}
+	f.Palette[5] = color.RGBA{0, 0, 255, 255}
+	f.Palette[6] = color.RGBA{120, 130, 128, 255}
+
+	tests := []struct {
+		name  string
+		index int
+		want  blocks.BlockType
+	}{
+		{"exact match", 1, red},
+		{"nearest match", 2, red},
+		{"fully transparent", 3, blocks.Empty},
+		{"partly transparent", 4, green},
+		{"invisible and non solid blocks are skipped", 5, grey},
+		{"grey", 6, grey},
+	}
+
+	m := NearestBlocks(r, f)
+
+	for _, tt := range tests {
+		if got := m[tt.index]; got != tt.want {
+			t.Errorf("%s: palette %d maps to %d, want %d", tt.name, tt.index, got, tt.want)
+		}
+	}
+
+	if m[0] != blocks.Empty {
+		t.Errorf("unused palette entry 0 maps to %d, want empty", m[0])
+	}
+}
+
+func uniform(r, g, b float64) [6]mgl64.Vec3 {
+	c := mgl64.Vec3{r, g, b}
+
+	return [6]mgl64.Vec3{c, c, c, c, c, c}
+}
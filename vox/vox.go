@@ -0,0 +1,218 @@
+// Package vox reads MagicaVoxel .vox models and stamps them into the world.
+package vox
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"image/color"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// MaxSize is the largest a model can be along each axis, as voxel coordinates are a single byte.
+const MaxSize = 256
+
+// Voxel is a single filled cell of a model. Index is its colour in the palette, from 1 to 255.
+type Voxel struct {
+	X, Y, Z uint8
+	Index   uint8
+}
+
+// Model is one model from a .vox file. MagicaVoxel treats Z as up. Every voxel lies within Size.
+type Model struct {
+	Size   [3]int
+	Voxels []Voxel
+}
+
+// File is the contents of a .vox file. Palette is indexed by a voxel's Index; entry 0 is unused.
+// HasPalette is false when the file has no RGBA chunk, in which case Palette holds MagicaVoxel's default
+// palette.
+type File struct {
+	Models     []Model
+	Palette    [256]color.RGBA
+	HasPalette bool
+}
+
+// Decode reads a .vox file. Chunks other than SIZE, XYZI and RGBA, such as materials and the scene
+// graph, are skipped.
+func Decode(r io.Reader) (*File, error) {
+	br := bufio.NewReader(r)
+
+	var header struct {
+		Magic   [4]byte
+		Version int32
+	}
+	if err := binary.Read(br, binary.LittleEndian, &header); err != nil {
+		return nil, fmt.Errorf("vox: reading header: %v", err)
+	}
+
+	if string(header.Magic[:]) != "VOX " {
+		return nil, fmt.Errorf("vox: not a .vox file")
+	}
+
+	id, content, children, err := readChunkHeader(br)
+	if err != nil {
+		return nil, err
+	}
+
+	if id != "MAIN" {
+		return nil, fmt.Errorf("vox: expected MAIN chunk, found %q", id)
+	}
+
+	if _, err := br.Discard(int(content)); err != nil {
+		return nil, fmt.Errorf("vox: reading MAIN: %v", err)
+	}
+
+	f := &File{Palette: DefaultPalette}
+	lr := &io.LimitedReader{R: br, N: int64(children)}
+
+	var size *[3]int
+
+	for lr.N > 0 {
+		id, content, children, err := readChunkHeader(lr)
+		if err != nil {
+			return nil, err
+		}
+
+		if int64(content)+int64(children) > lr.N {
+			return nil, fmt.Errorf("vox: %s chunk overruns the file", id)
+		}
+
+		// Read rather than allocate up front so that a corrupt size cannot claim more memory than the
+		// file holds.
+		data, err := ioutil.ReadAll(io.LimitReader(lr, int64(content)))
+		if err != nil {
+			return nil, fmt.Errorf("vox: reading %s: %v", id, err)
+		}
+
+		if len(data) != int(content) {
+			return nil, fmt.Errorf("vox: reading %s: %v", id, io.ErrUnexpectedEOF)
+		}
+
+		if _, err := io.CopyN(ioutil.Discard, lr, int64(children)); err != nil {
+			return nil, fmt.Errorf("vox: reading %s: %v", id, err)
+		}
+
+		switch id {
+		case "SIZE":
+			if len(data) < 12 {
+				return nil, fmt.Errorf("vox: SIZE chunk is too short")
+			}
+
+			size = &[3]int{}
+			for i := range size {
+				size[i] = int(int32(binary.LittleEndian.Uint32(data[i*4:])))
+				if size[i] < 0 || size[i] > MaxSize {
+					return nil, fmt.Errorf("vox: model size %d is outside 0 to %d", size[i], MaxSize)
+				}
+			}
+		case "XYZI":
+			if size == nil {
+				return nil, fmt.Errorf("vox: XYZI chunk without a SIZE chunk")
+			}
+
+			m, err := decodeVoxels(*size, data)
+			if err != nil {
+				return nil, err
+			}
+
+			f.Models = append(f.Models, m)
+			size = nil
+		case "RGBA":
+			if len(data) < 256*4 {
+				return nil, fmt.Errorf("vox: RGBA chunk is too short")
+			}
+
+			// The colour at index i in the voxel data is stored i-1 in the RGBA chunk.
+			for i := 0; i < 255; i++ {
+				f.Palette[i+1] = color.RGBA{data[i*4], data[i*4+1], data[i*4+2], data[i*4+3]}
+			}
+			f.HasPalette = true
+		}
+	}
+
+	return f, nil
+}
+
+// ReadFile decodes the .vox file at path.
+func ReadFile(path string) (*File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Decode(f)
+}
+
+func readChunkHeader(r io.Reader) (id string, content, children uint32, err error) {
+	var h struct {
+		ID                [4]byte
+		Content, Children uint32
+	}
+
+	if err := binary.Read(r, binary.LittleEndian, &h); err != nil {
+		return "", 0, 0, fmt.Errorf("vox: reading chunk header: %v", err)
+	}
+
+	return string(h.ID[:]), h.Content, h.Children, nil
+}
+
+func decodeVoxels(size [3]int, data []byte) (Model, error) {
+	m := Model{Size: size}
+
+	if len(data) < 4 {
+		return m, fmt.Errorf("vox: XYZI chunk is too short")
+	}
+
+	n := int(binary.LittleEndian.Uint32(data))
+	if len(data) < 4+n*4 {
+		return m, fmt.Errorf("vox: XYZI chunk holds %d bytes, too few for %d voxels", len(data), n)
+	}
+
+	m.Voxels = make([]Voxel, n)
+	for i := range m.Voxels {
+		v := data[4+i*4:]
+		m.Voxels[i] = Voxel{X: v[0], Y: v[1], Z: v[2], Index: v[3]}
+
+		if int(v[0]) >= size[0] || int(v[1]) >= size[1] || int(v[2]) >= size[2] {
+			return m, fmt.Errorf("vox: voxel at %d, %d, %d is outside the model's size %v", v[0], v[1], v[2], size)
+		}
+	}
+
+	return m, nil
+}
+
+// DefaultPalette is the palette MagicaVoxel uses for files without an RGBA chunk: the web safe colour
+// cube without black, from white down, followed by ramps of red, green, blue and grey.
+var DefaultPalette = defaultPalette()
+
+func defaultPalette() [256]color.RGBA {
+	var p [256]color.RGBA
+
+	i := 1
+	for r := 5; r >= 0; r-- {
+		for g := 5; g >= 0; g-- {
+			for b := 5; b >= 0; b-- {
+				if r == 0 && g == 0 && b == 0 {
+					continue
+				}
+
+				p[i] = color.RGBA{uint8(r * 0x33), uint8(g * 0x33), uint8(b * 0x33), 0xFF}
+				i++
+			}
+		}
+	}
+
+	ramp := []uint8{0xEE, 0xDD, 0xBB, 0xAA, 0x88, 0x77, 0x55, 0x44, 0x22, 0x11}
+	for _, mask := range []color.RGBA{{R: 1}, {G: 1}, {B: 1}, {R: 1, G: 1, B: 1}} {
+		for _, v := range ramp {
+			p[i] = color.RGBA{mask.R * v, mask.G * v, mask.B * v, 0xFF}
+			i++
+		}
+	}
+
+	return p
+}
@@ -0,0 +1,220 @@
+package vox
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+// chunk encodes a .vox chunk with the given content and children.
+func chunk(id string, content []byte, children ...[]byte) []byte {
+	var kids []byte
+	for _, c := range children {
+		kids = append(kids, c...)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(id)
+	binary.Write(&buf, binary.LittleEndian, uint32(len(content)))
+	binary.Write(&buf, binary.LittleEndian, uint32(len(kids)))
+	buf.Write(content)
+	buf.Write(kids)
+
+	return buf.Bytes()
+}
+
+// voxFile encodes a .vox file whose MAIN chunk holds the given chunks.
+func voxFile(children ...[]byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("VOX ")
+	binary.Write(&buf, binary.LittleEndian, int32(150))
+	buf.Write(chunk("MAIN", nil, children...))
+
+	return buf.Bytes()
+}
+
+func sizeChunk(x, y, z int32) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, [3]int32{x, y, z})
+
+	return chunk("SIZE", buf.Bytes())
+}
+
+func xyziChunk(voxels ...Voxel) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, uint32(len(voxels)))
+	for _, v := range voxels {
+		buf.Write([]byte{v.X, v.Y, v.Z, v.Index})
+	}
+
+	return chunk("XYZI", buf.Bytes())
+}
+
+// rgbaChunk encodes a palette where the colour at index i is grey i, apart from those given in set.
+func rgbaChunk(set map[int]color.RGBA) []byte {
+	data := make([]byte, 256*4)
+	for i := 1; i < 256; i++ {
+		c, ok := set[i]
+		if !ok {
+			c = color.RGBA{uint8(i), uint8(i), uint8(i), 0xFF}
+		}
+
+		copy(data[(i-1)*4:], []byte{c.R, c.G, c.B, c.A})
+	}
+
+	return chunk("RGBA", data)
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       []byte
+		models     []Model
+		hasPalette bool
+		palette    map[int]color.RGBA
+	}{
+		{
+			name: "no models",
+			data: voxFile(),
+		},
+		{
+			name:   "one model with the default palette",
+			data:   voxFile(sizeChunk(2, 3, 4), xyziChunk(Voxel{0, 0, 0, 1}, Voxel{1, 2, 3, 200})),
+			models: []Model{{Size: [3]int{2, 3, 4}, Voxels: []Voxel{{0, 0, 0, 1}, {1, 2, 3, 200}}}},
+			palette: map[int]color.RGBA{
+				1:   {0xFF, 0xFF, 0xFF, 0xFF},
+				215: {0x00, 0x00, 0x33, 0xFF},
+				216: {0xEE, 0x00, 0x00, 0xFF},
+				255: {0x11, 0x11, 0x11, 0xFF},
+			},
+		},
+		{
+			name: "two models with a custom palette",
+			data: voxFile(
+				sizeChunk(1, 1, 1), xyziChunk(Voxel{0, 0, 0, 7}),
+				sizeChunk(256, 256, 256), xyziChunk(Voxel{255, 255, 255, 9}),
+				rgbaChunk(map[int]color.RGBA{7: {10, 20, 30, 255}, 9: {1, 2, 3, 0}}),
+			),
+			models: []Model{
+				{Size: [3]int{1, 1, 1}, Voxels: []Voxel{{0, 0, 0, 7}}},
+				{Size: [3]int{256, 256, 256}, Voxels: []Voxel{{255, 255, 255, 9}}},
+			},
+			hasPalette: true,
+			palette: map[int]color.RGBA{
+				1:   {1, 1, 1, 255},
+				7:   {10, 20, 30, 255},
+				9:   {1, 2, 3, 0},
+				255: {255, 255, 255, 255},
+			},
+		},
+		{
+			name: "unknown chunks and their children are skipped",
+			data: voxFile(
+				chunk("nTRN", []byte{1, 2, 3}, chunk("nSHP", []byte{4, 5})),
+				sizeChunk(1, 1, 1), xyziChunk(Voxel{0, 0, 0, 1}),
+				chunk("MATL", []byte("material")),
+			),
+			models: []Model{{Size: [3]int{1, 1, 1}, Voxels: []Voxel{{0, 0, 0, 1}}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := Decode(bytes.NewReader(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(f.Models) != len(tt.models) {
+				t.Fatalf("decoded %d models, want %d", len(f.Models), len(tt.models))
+			}
+
+			for i, want := range tt.models {
+				got := f.Models[i]
+				if got.Size != want.Size {
+					t.Errorf("model %d size is %v, want %v", i, got.Size, want.Size)
+				}
+
+				if len(got.Voxels) != len(want.Voxels) {
+					t.Errorf("model %d has %d voxels, want %d", i, len(got.Voxels), len(want.Voxels))
+					continue
+				}
+
+				for j := range want.Voxels {
+					if got.Voxels[j] != want.Voxels[j] {
+						t.Errorf("model %d voxel %d is %v, want %v", i, j, got.Voxels[j], want.Voxels[j])
+					}
+				}
+			}
+
+			if f.HasPalette != tt.hasPalette {
+				t.Errorf("HasPalette is %v, want %v", f.HasPalette, tt.hasPalette)
+			}
+
+			for i, want := range tt.palette {
+				if got := f.Palette[i]; got != want {
+					t.Errorf("palette %d is %v, want %v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeRejects(t *testing.T) {
+	// MAIN claims more children than follow it.
+	overrun := voxFile(sizeChunk(1, 1, 1))
+	binary.LittleEndian.PutUint32(overrun[16:], 1000)
+
+	// A child chunk claims more content than its parent holds.
+	nested := voxFile(chunk("nTRN", nil, chunk("nSHP", []byte{1, 2})))
+	binary.LittleEndian.PutUint32(nested[8+12+4:], 1<<31)
+
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"wrong magic", []byte("VXSC\x96\x00\x00\x00"), "not a .vox file"},
+		{"missing MAIN", append([]byte("VOX \x96\x00\x00\x00"), chunk("SIZE", nil)...), "expected MAIN"},
+		{"truncated header", []byte("VOX "), "reading header"},
+		{"children past the end of the file", overrun, "reading chunk header"},
+		{"chunk larger than its parent", nested, "overruns the file"},
+		{"voxels without a size", voxFile(xyziChunk(Voxel{0, 0, 0, 1})), "without a SIZE"},
+		{"short SIZE", voxFile(chunk("SIZE", []byte{1, 0, 0, 0})), "too short"},
+		{"negative size", voxFile(sizeChunk(-1, 1, 1)), "outside 0 to 256"},
+		{"oversize", voxFile(sizeChunk(1, 257, 1)), "outside 0 to 256"},
+		{"voxel outside the model", voxFile(sizeChunk(2, 2, 2), xyziChunk(Voxel{0, 2, 0, 1})), "outside the model"},
+		{"voxel count past the chunk", voxFile(sizeChunk(2, 2, 2), chunk("XYZI", []byte{9, 0, 0, 0, 0, 0, 0, 1})), "too few for 9 voxels"},
+		{"short RGBA", voxFile(chunk("RGBA", make([]byte, 255*4))), "too short"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode(bytes.NewReader(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Decode error is %v, want one mentioning %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultPalette(t *testing.T) {
+	if DefaultPalette[0] != (color.RGBA{}) {
+		t.Errorf("palette entry 0 is %v, want it unused", DefaultPalette[0])
+	}
+
+	seen := make(map[color.RGBA]int)
+	for i := 1; i < 256; i++ {
+		c := DefaultPalette[i]
+		if c.A != 0xFF {
+			t.Errorf("palette entry %d is %v, want it opaque", i, c)
+		}
+
+		if j, ok := seen[c]; ok {
+			t.Errorf("palette entries %d and %d are both %v", j, i, c)
+		}
+		seen[c] = i
+	}
+}